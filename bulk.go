@@ -0,0 +1,164 @@
+package bolster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/nochso/bolster/bytesort"
+	"github.com/nochso/bolster/errlist"
+)
+
+// ItemError occurs when a single item of a bulk action fails.
+type ItemError struct {
+	Index int // position of the item in the slice passed to the bulk action
+	Err   error
+}
+
+// Error implements the built-in error interface.
+func (e ItemError) Error() string {
+	return fmt.Sprintf("item %d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the inner error.
+func (e ItemError) Unwrap() error {
+	return e.Err
+}
+
+// InsertMany inserts all items of a slice of structs or pointers to structs.
+//
+// It behaves like calling Insert for each item, however the type is only
+// validated once and items are written in order of their IDs. Items without
+// an ID keep their relative order when autoincremented.
+//
+// A failing item does not stop the remaining items from being written. Each
+// failure is reported as an ItemError, multiple failures within an
+// errlist.Errors. Any failure fails the transaction.
+func (tx *Tx) InsertMany(v interface{}) error {
+	return tx.writeMany(v, insert)
+}
+
+// UpsertMany upserts all items of a slice of structs or pointers to structs.
+// See InsertMany for details.
+func (tx *Tx) UpsertMany(v interface{}) error {
+	return tx.writeMany(v, upsert)
+}
+
+// DeleteMany deletes all items of a slice of structs or pointers to structs.
+// See InsertMany for details.
+func (tx *Tx) DeleteMany(v interface{}) error {
+	return tx.writeMany(v, delete)
+}
+
+type bulkItem struct {
+	index int
+	rv    reflect.Value // struct value
+	v     interface{}   // pointer to the struct
+	key   []byte        // sort key of the ID
+}
+
+func (tx *Tx) writeMany(v interface{}, action txAction) error {
+	st, items, err := tx.validateSlice(v, action)
+	if tx.errs.HasError() {
+		return tx.addErr(ErrBadTransaction)
+	}
+	if err != nil {
+		return tx.addErr(err)
+	}
+	errs := errlist.New()
+	sorted := items[:0]
+	for _, item := range items {
+		if item.v == nil {
+			errs.Append(ItemError{Index: item.index, Err: errors.New("nil pointer")})
+			continue
+		}
+		item.key, err = st.ID.sortKey(item.rv)
+		if err != nil {
+			errs.Append(ItemError{Index: item.index, Err: err})
+			continue
+		}
+		sorted = append(sorted, item)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].key, sorted[j].key) < 0
+	})
+	for _, item := range sorted {
+		err = tx.ctx.Err()
+		if err != nil {
+			errs.Append(err)
+			break
+		}
+		if action == delete {
+			err = tx.remove(st, item.rv, item.v, true)
+		} else {
+			err = tx.save(st, item.rv, item.v, action)
+		}
+		if err != nil {
+			errs.Append(ItemError{Index: item.index, Err: err})
+		}
+	}
+	return tx.addErr(errs.ErrorOrNil())
+}
+
+// validateSlice checks that v is a slice of registered structs or pointers to
+// them and returns its items.
+func (tx *Tx) validateSlice(v interface{}, action txAction) (structType, []bulkItem, error) {
+	tx.errf = newErrorFactory(action)
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return structType{}, nil, errors.New("invalid interface")
+	}
+	if rv.Kind() != reflect.Slice {
+		return structType{}, nil, fmt.Errorf("expected slice, got %v", rv.Kind())
+	}
+	rt := rv.Type().Elem()
+	isPtr := rt.Kind() == reflect.Ptr
+	if isPtr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		return structType{}, nil, fmt.Errorf("expected slice of structs, got slice of %v", rt.Kind())
+	}
+	st, ok := tx.store.types[rt]
+	if !ok {
+		return st, nil, fmt.Errorf("unregistered struct: %v", rt)
+	}
+	tx.errf = newErrorFactory(action, st)
+	items := make([]bulkItem, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		e := rv.Index(i)
+		if isPtr {
+			if e.IsNil() {
+				// reported as a failure of this item only
+				items = append(items, bulkItem{index: i})
+				continue
+			}
+			e = e.Elem()
+		}
+		items = append(items, bulkItem{index: i, rv: e, v: e.Addr().Interface()})
+	}
+	return st, items, tx.ctx.Err()
+}
+
+// sortKey returns the ID of the struct rv encoded like it is stored.
+// Non-integer and composite IDs are encoded like their mapping in the ID index.
+func (i idField) sortKey(rv reflect.Value) ([]byte, error) {
+	if !i.isInteger() {
+		var key []byte
+		for _, f := range i.IntIndex.Fields {
+			var err error
+			key, err = f.append(key, rv.Field(f.StructPos).Interface())
+			if err != nil {
+				return nil, err
+			}
+		}
+		return key, nil
+	}
+	f := rv.Field(i.StructPos)
+	if k := f.Kind(); k >= reflect.Int && k <= reflect.Int64 {
+		return bytesort.AppendInt64(nil, f.Int()), nil
+	}
+	return bytesort.AppendUint64(nil, f.Uint()), nil
+}
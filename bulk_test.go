@@ -0,0 +1,136 @@
+package bolster_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+func TestTx_InsertMany(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIDAndField{}, structWithAutoincrement{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []structWithIDAndField{{3, "c"}, {1, "a"}, {2, "b"}}
+	incs := []*structWithAutoincrement{{}, {}, {}}
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.InsertMany(items)
+		return tx.InsertMany(incs)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, item := range incs {
+		if int(item.ID) != i+1 {
+			t.Errorf("expected autoincremented ID %d at index %d, got %d", i+1, i, item.ID)
+		}
+	}
+	internal.GoldStore(t, st, *updateGold)
+}
+
+func TestTx_InsertMany_errors(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(&structWithIDAndField{ID: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := internal.DumpStore(st)
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.InsertMany([]*structWithIDAndField{{ID: 2}, nil, {ID: 1}, {ID: 3}})
+	})
+	t.Log(err)
+	var txErr bolster.Error
+	if !errors.As(err, &txErr) {
+		t.Fatalf("expected bolster.Error, got %T", err)
+	}
+	var indexes []int
+	for _, err := range txErr.Err.(interface{ Unwrap() []error }).Unwrap() {
+		indexes = append(indexes, err.(bolster.ItemError).Index)
+	}
+	sort.Ints(indexes)
+	if !reflect.DeepEqual(indexes, []int{1, 2}) {
+		t.Errorf("expected failures of items [1 2], got %v", indexes)
+	}
+	assertSameDump(t, exp, internal.DumpStore(st))
+
+	for _, v := range []interface{}{
+		nil,
+		structWithIDAndField{},
+		[]int{1},
+		[]structWithoutID{{}},
+	} {
+		err = st.Write(func(tx *bolster.Tx) error {
+			return tx.InsertMany(v)
+		})
+		if err == nil {
+			t.Errorf("expected error for %T, got nil", v)
+		}
+	}
+}
+
+func TestTx_UpsertMany(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.InsertMany([]structWithIDAndField{{1, "a"}, {2, "b"}})
+		return tx.UpsertMany([]*structWithIDAndField{{3, "c"}, {1, "A"}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	err = st.Read(func(tx *bolster.Tx) error {
+		item := &structWithIDAndField{}
+		return tx.Each(item, func() error {
+			names = append(names, item.Name)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"A", "b", "c"}; !reflect.DeepEqual(names, exp) {
+		t.Errorf("expected %v, got %v", exp, names)
+	}
+}
+
+func TestTx_DeleteMany(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(&structWithIDAndField{ID: 2})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := internal.DumpStore(st)
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.InsertMany([]structWithIDAndField{{1, "a"}, {3, "c"}})
+		return tx.DeleteMany([]structWithIDAndField{{ID: 3}, {ID: 1}, {ID: 4}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameDump(t, exp, internal.DumpStore(st))
+}
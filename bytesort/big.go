@@ -0,0 +1,210 @@
+package bytesort
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Decimal is a string containing a decimal number, e.g. "-123.4500" or "1e-3".
+//
+// Decimals are encoded by numeric value instead of bytewise, i.e. "10" sorts
+// after "9" and "1.50" is encoded like "1.5".
+type Decimal string
+
+// Leading byte of numbers with arbitrary precision.
+const (
+	classNil byte = iota
+	classNegInf
+	classNeg
+	classZero
+	classPos
+	classPosInf
+)
+
+// AppendBigInt appends the encoding of a big.Int to dst.
+//
+// The encoding starts with a byte for the sign followed by the length of the
+// absolute value (4 bytes) and its bytes. Bytes following the sign are
+// inverted for negative numbers. A nil pointer sorts before any number.
+func AppendBigInt(dst []byte, v *big.Int) []byte {
+	if v == nil {
+		return append(dst, classNil)
+	}
+	switch v.Sign() {
+	case 0:
+		return append(dst, classZero)
+	case 1:
+		dst = append(dst, classPos)
+	default:
+		dst = append(dst, classNeg)
+	}
+	start := len(dst)
+	n := (v.BitLen() + 7) / 8
+	dst = appendUint32(dst, uint32(n))
+	dst = append(dst, make([]byte, n)...)
+	v.FillBytes(dst[len(dst)-n:])
+	if v.Sign() < 0 {
+		invert(dst[start:])
+	}
+	return dst
+}
+
+// AppendBigFloat appends the encoding of a big.Float to dst.
+//
+// The encoding starts with a byte for the sign or infinity followed by the
+// binary exponent (4 bytes) and the mantissa. The mantissa's bits are stored
+// left-aligned without trailing zero bytes. Zero bytes within the mantissa are
+// escaped as 0x00 0xff and the end of the mantissa is marked by 0x00 0x01.
+// Bytes following the sign are inverted for negative numbers. Positive and
+// negative zero are encoded the same. A nil pointer sorts before any number.
+func AppendBigFloat(dst []byte, v *big.Float) []byte {
+	if v == nil {
+		return append(dst, classNil)
+	}
+	switch {
+	case v.IsInf() && v.Signbit():
+		return append(dst, classNegInf)
+	case v.IsInf():
+		return append(dst, classPosInf)
+	case v.Sign() == 0:
+		return append(dst, classZero)
+	case v.Sign() > 0:
+		dst = append(dst, classPos)
+	default:
+		dst = append(dst, classNeg)
+	}
+	start := len(dst)
+	mant := new(big.Float)
+	exp := v.MantExp(mant)
+	dst = appendUint32(dst, uint32(int32(exp))^(1<<31))
+	// scale the mantissa 0.5 <= |mant| < 1 to an integer with the minimum
+	// amount of bits required, then shift the bits to the left of the last byte.
+	prec := mant.MinPrec()
+	mant.SetMantExp(mant, int(prec)+int((8-prec%8)%8))
+	bits, _ := mant.Abs(mant).Int(nil)
+	for _, b := range bits.Bytes() {
+		if b == 0 {
+			dst = append(dst, 0, 0xff)
+		} else {
+			dst = append(dst, b)
+		}
+	}
+	dst = append(dst, 0, 1)
+	if v.Sign() < 0 {
+		invert(dst[start:])
+	}
+	return dst
+}
+
+// AppendDecimal appends the encoding of a Decimal to dst.
+//
+// The decimal is normalised to the form 0.d1d2...dn × 10^exp without leading
+// or trailing zeros. The encoding starts with a byte for the sign followed by
+// the exponent (4 bytes), the ASCII digits and a terminating zero byte.
+// Bytes following the sign are inverted for negative numbers.
+func AppendDecimal(dst []byte, v Decimal) ([]byte, error) {
+	neg, digits, exp, err := parseDecimal(string(v))
+	if err != nil {
+		return dst, err
+	}
+	switch {
+	case len(digits) == 0:
+		return append(dst, classZero), nil
+	case neg:
+		dst = append(dst, classNeg)
+	default:
+		dst = append(dst, classPos)
+	}
+	start := len(dst)
+	dst = appendUint32(dst, uint32(exp)^(1<<31))
+	dst = append(dst, digits...)
+	dst = append(dst, 0)
+	if neg {
+		invert(dst[start:])
+	}
+	return dst, nil
+}
+
+// parseDecimal returns the significant digits of s and the exponent of
+// 0.digits × 10^exp.
+func parseDecimal(s string) (neg bool, digits string, exp int32, err error) {
+	errInvalid := fmt.Errorf("bytesort.Encode: invalid decimal %q", s)
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		neg = s[i] == '-'
+		i++
+	}
+	buf := make([]byte, 0, len(s))
+	var e int64
+	seenDigit, seenDot := false, false
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			seenDigit = true
+			if c == '0' && len(buf) == 0 {
+				// leading zero: only significant after the dot
+				if seenDot {
+					e--
+				}
+				continue
+			}
+			buf = append(buf, c)
+			if !seenDot {
+				e++
+			}
+		case c == '.' && !seenDot:
+			seenDot = true
+		case (c == 'e' || c == 'E') && seenDigit:
+			x, ok := parseExponent(s[i+1:])
+			if !ok {
+				return false, "", 0, errInvalid
+			}
+			e += x
+			i = len(s)
+		default:
+			return false, "", 0, errInvalid
+		}
+	}
+	if !seenDigit {
+		return false, "", 0, errInvalid
+	}
+	for len(buf) > 0 && buf[len(buf)-1] == '0' {
+		buf = buf[:len(buf)-1]
+	}
+	if len(buf) == 0 {
+		return false, "", 0, nil
+	}
+	if e < -1<<31 || e > 1<<31-1 {
+		return false, "", 0, fmt.Errorf("bytesort.Encode: exponent of decimal %q out of range", s)
+	}
+	return neg, string(buf), int32(e), nil
+}
+
+func parseExponent(s string) (int64, bool) {
+	neg := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if len(s) == 0 || len(s) > 10 {
+		return 0, false
+	}
+	var x int64
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		x = x*10 + int64(s[i]-'0')
+	}
+	if neg {
+		x = -x
+	}
+	return x, true
+}
+
+func invert(b []byte) {
+	for i := range b {
+		b[i] = ^b[i]
+	}
+}
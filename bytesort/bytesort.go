@@ -13,9 +13,9 @@
 package bytesort
 
 import (
-	"encoding/binary"
 	"fmt"
 	"math"
+	"math/big"
 	"time"
 )
 
@@ -26,9 +26,10 @@ import (
 // types.
 //
 // When err == nil the length of the byte slice is always > 0. The length is
-// always the same for values of the same type. Encoded strings are the only
-// exception as they vary in length.
+// always the same for values of the same type. Encoded strings and numbers of
+// arbitrary precision are the only exception as they vary in length.
 // Empty strings are encoded as 0x00 to allow using them as bolt bucket names.
+// The string "\x00" has the same encoding.
 //
 // Sortability is the only requirement. None of the encodings retain any type
 // information because decoding of binary back into a value is out of scope.
@@ -36,119 +37,160 @@ import (
 // The following types are supported:
 //
 //	bool
-//	float32 float64
+//	float32 float64 (-0 equals +0, NaN sorts after +Inf)
 //	int int8 int16 int32 int64
 //	uint uint8 uint16 uint32 uint64
-//	string    (case-sensitive)
+//	string    (case-sensitive, see Collation for alternatives)
 //	time.Time (normalised to UTC)
-func Encode(v interface{}) (b []byte, err error) {
-	switch v.(type) {
+//	*big.Int *big.Float Decimal (ordered by numeric value)
+//	[16]byte  (e.g. UUIDs, compared bytewise)
+func Encode(v interface{}) ([]byte, error) {
+	return Append(nil, v)
+}
+
+// Append encodes v like Encode and appends the result to dst.
+//
+// The extended slice is returned. On error dst is returned unchanged.
+// Reusing dst avoids allocating a new slice for every value.
+func Append(dst []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
 	case string:
-		b = []byte(v.(string))
-		// Special case for empty strings because empty bucket names are not
-		// allowed. Use a zero byte to represent an empty string.
-		if len(b) == 0 {
-			b = append(b, 0)
-		}
-		return
+		return AppendString(dst, v), nil
 	case time.Time:
-		return encodeTime(v.(time.Time))
+		return AppendTime(dst, v), nil
 	case float64:
-		return encodeFloat64(v.(float64)), nil
+		return AppendFloat64(dst, v), nil
 	case float32:
-		return encodeFloat32(v.(float32)), nil
-	}
-
-	b, err = encodeInt(v)
-	if err != nil {
-		return
-	}
-	return
-}
-
-func encodeInt(data interface{}) ([]byte, error) {
-	n := intDataSize(data)
-	if n == 0 {
-		return nil, fmt.Errorf("bytesort.Encode: unsupported type %T", data)
-	}
-	bs := make([]byte, n)
-	switch v := data.(type) {
+		return appendUint32(dst, float32Bits(v)), nil
 	case bool:
 		if v {
-			bs[0] = 1
-		} else {
-			bs[0] = 0
+			return append(dst, 1), nil
 		}
+		return append(dst, 0), nil
 	case int8:
-		bs[0] = byte(v)
+		return append(dst, uint8(v)^0x80), nil
 	case uint8:
-		bs[0] = v
+		return append(dst, v), nil
 	case int16:
-		binary.BigEndian.PutUint16(bs, uint16(v))
+		return appendUint16(dst, uint16(v)^0x8000), nil
 	case uint16:
-		binary.BigEndian.PutUint16(bs, v)
+		return appendUint16(dst, v), nil
 	case int32:
-		binary.BigEndian.PutUint32(bs, uint32(v))
+		return appendUint32(dst, uint32(v)^0x80000000), nil
 	case uint32:
-		binary.BigEndian.PutUint32(bs, v)
+		return appendUint32(dst, v), nil
 	case int64:
-		binary.BigEndian.PutUint64(bs, uint64(v))
+		return AppendInt64(dst, v), nil
 	case int:
-		binary.BigEndian.PutUint64(bs, uint64(int64(v)))
+		return AppendInt64(dst, int64(v)), nil
 	case uint64:
-		binary.BigEndian.PutUint64(bs, v)
+		return AppendUint64(dst, v), nil
 	case uint:
-		binary.BigEndian.PutUint64(bs, uint64(v))
-	}
-	switch data.(type) {
-	case int64, int32, int16, int8, int:
-		// The order is almost correct, however ascending positive numbers are
-		// currently before ascending negative numbers. Flip the first bit of
-		// the two's complement: both ranges switch places, resulting in
-		// consecutively ascending sort order.
-		bs[0] ^= 0x80
+		return AppendUint64(dst, uint64(v)), nil
+	case *big.Int:
+		return AppendBigInt(dst, v), nil
+	case *big.Float:
+		return AppendBigFloat(dst, v), nil
+	case Decimal:
+		return AppendDecimal(dst, v)
+	case [16]byte:
+		return append(dst, v[:]...), nil
 	}
-	return bs, nil
+	return dst, fmt.Errorf("bytesort.Encode: unsupported type %T", v)
 }
 
-// intDataSize returns the size of the data required to represent the data when encoded.
-// It returns zero if the type cannot be implemented by the fast path in Read or Write.
-func intDataSize(data interface{}) int {
-	switch data.(type) {
-	case bool, int8, uint8:
-		return 1
-	case int16, uint16:
-		return 2
-	case int32, uint32:
-		return 4
-	case int64, uint64, int, uint:
-		return 8
+// AppendString appends the encoding of a string to dst.
+func AppendString(dst []byte, v string) []byte {
+	// Special case for empty strings because empty bucket names are not
+	// allowed. Use a zero byte to represent an empty string.
+	if len(v) == 0 {
+		return append(dst, 0)
 	}
-	return 0
+	return append(dst, v...)
+}
+
+// AppendInt64 appends the encoding of an int64 to dst.
+func AppendInt64(dst []byte, v int64) []byte {
+	// The order of the two's complement is almost correct, however ascending
+	// positive numbers would be before ascending negative numbers. Flip the
+	// first bit: both ranges switch places, resulting in consecutively
+	// ascending sort order.
+	return appendUint64(dst, uint64(v)^(1<<63))
+}
+
+// AppendUint64 appends the encoding of an uint64 to dst.
+func AppendUint64(dst []byte, v uint64) []byte {
+	return appendUint64(dst, v)
+}
+
+// AppendFloat64 appends the encoding of a float64 to dst.
+func AppendFloat64(dst []byte, v float64) []byte {
+	return appendUint64(dst, float64Bits(v))
 }
 
-// http://stereopsis.com/radix.html
-func encodeFloat64(v float64) []byte {
+// TimeEncodingVersion is the version of the current time.Time encoding.
+//
+// Version 1 was derived from time.Time.MarshalBinary and counted seconds since
+// January 1, year 1. Version 2 is described at AppendTime.
+// Keys using different versions must not be mixed.
+const TimeEncodingVersion = 2
+
+// FloatEncodingVersion is the version of the current float32 and float64
+// encoding.
+//
+// Version 1 encoded negative zero and NaN values by their bits. Version 2
+// encodes negative zero like positive zero and all NaN values the same.
+// Keys using different versions must not be mixed.
+const FloatEncodingVersion = 2
+
+// AppendTime appends the encoding of a time.Time to dst.
+//
+// The encoding is always 12 bytes long and independent of the time zone and
+// monotonic clock reading:
+//
+//	8 bytes  seconds since the Unix epoch (int64, sign bit flipped, big endian)
+//	4 bytes  nanoseconds within the second (uint32, big endian)
+func AppendTime(dst []byte, v time.Time) []byte {
+	dst = AppendInt64(dst, v.Unix())
+	return appendUint32(dst, uint32(v.Nanosecond()))
+}
+
+// float64Bits returns the bits of v in an order that is sortable as unsigned
+// integers. See http://stereopsis.com/radix.html
+//
+// Negative zero is encoded like positive zero. NaN values are encoded the same
+// and sort after positive infinity.
+func float64Bits(v float64) uint64 {
+	if v == 0 {
+		v = 0
+	} else if v != v {
+		v = math.NaN()
+	}
 	bits := math.Float64bits(v)
-	bits ^= -(bits >> 63) | (1 << 63)
-	b := make([]byte, 8)
-	binary.BigEndian.PutUint64(b, bits)
-	return b
+	return bits ^ (-(bits >> 63) | (1 << 63))
 }
 
-func encodeFloat32(v float32) []byte {
+func float32Bits(v float32) uint32 {
+	if v == 0 {
+		v = 0
+	} else if v != v {
+		v = float32(math.NaN())
+	}
 	bits := math.Float32bits(v)
-	bits ^= -(bits >> 31) | (1 << 31)
-	b := make([]byte, 4)
-	binary.BigEndian.PutUint32(b, bits)
-	return b
+	return bits ^ (-(bits >> 31) | (1 << 31))
 }
 
-func encodeTime(v time.Time) ([]byte, error) {
-	b, err := v.MarshalBinary()
-	if err != nil {
-		return nil, fmt.Errorf("bytesort.Encode: %s", err)
-	}
-	// Strip version and time zone, leaving only the sort-relevant parts
-	return b[1 : len(b)-2], nil
+func appendUint16(dst []byte, v uint16) []byte {
+	return append(dst, byte(v>>8), byte(v))
+}
+
+func appendUint32(dst []byte, v uint32) []byte {
+	return append(dst, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	return append(dst,
+		byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v),
+	)
 }
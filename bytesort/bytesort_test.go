@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
 	"sort"
 	"strings"
@@ -25,6 +26,7 @@ var (
 func BenchmarkEncode(b *testing.B) {
 	for name, values := range sortTests {
 		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
 			for i := 0; i < b.N; i++ {
 				for _, v := range values {
 					bytesort.Encode(v)
@@ -48,10 +50,57 @@ func BenchmarkEncode_parallel(b *testing.B) {
 	}
 }
 
+func BenchmarkAppend(b *testing.B) {
+	for name, values := range sortTests {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			buf := make([]byte, 0, 64)
+			for i := 0; i < b.N; i++ {
+				for _, v := range values {
+					buf, _ = bytesort.Append(buf[:0], v)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAppendInt64(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 8)
+	for i := 0; i < b.N; i++ {
+		buf = bytesort.AppendInt64(buf[:0], int64(i))
+	}
+}
+
+func BenchmarkAppendString(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 64)
+	for i := 0; i < b.N; i++ {
+		buf = bytesort.AppendString(buf[:0], "Aaron")
+	}
+}
+
+func BenchmarkAppendTime(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 12)
+	t := time.Now()
+	for i := 0; i < b.N; i++ {
+		buf = bytesort.AppendTime(buf[:0], t)
+	}
+}
+
 var encodeErrorTests = []interface{}{
 	nil,
 	[]string{},
 	map[string]string{},
+	bytesort.Decimal(""),
+	bytesort.Decimal("-"),
+	bytesort.Decimal("."),
+	bytesort.Decimal("1.2.3"),
+	bytesort.Decimal("1e"),
+	bytesort.Decimal("e1"),
+	bytesort.Decimal("1e99999999999"),
+	bytesort.Decimal("1,5"),
 }
 
 func TestEncode_error(t *testing.T) {
@@ -68,6 +117,30 @@ func TestEncode_error(t *testing.T) {
 	}
 }
 
+// variableLength lists the types of sortTests with varying encoded length.
+var variableLength = map[string]bool{
+	"string":    true,
+	"big.Int":   true,
+	"big.Float": true,
+	"Decimal":   true,
+}
+
+func bigInt(s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big.Int: " + s)
+	}
+	return i
+}
+
+func bigFloat(s string) *big.Float {
+	f, _, err := big.ParseFloat(s, 10, 200, big.ToNearestEven)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
 var sortTests = map[string][]interface{}{
 	"uint8": {
 		byte(0),
@@ -168,6 +241,13 @@ var sortTests = map[string][]interface{}{
 		false,
 		true,
 	},
+	"[16]byte": {
+		[16]byte{},
+		[16]byte{15: 1},
+		[16]byte{0: 1},
+		[16]byte{0: 1, 15: 0xff},
+		[16]byte{0: 0xff, 1: 0xff, 2: 0xff, 3: 0xff, 4: 0xff, 5: 0xff, 6: 0xff, 7: 0xff, 8: 0xff, 9: 0xff, 10: 0xff, 11: 0xff, 12: 0xff, 13: 0xff, 14: 0xff, 15: 0xff},
+	},
 	"string": {
 		"",
 		"  ZOO",
@@ -183,6 +263,9 @@ var sortTests = map[string][]interface{}{
 		"bö",
 	},
 	"time.Time": {
+		time.Time{},
+		time.Date(1969, time.December, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(1969, time.December, 31, 23, 59, 59, 999999999, time.UTC),
 		time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC),
 		time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC).In(location),
 		time.Date(1970, time.January, 1, 0, 0, 0, 1, time.UTC),
@@ -200,17 +283,77 @@ var sortTests = map[string][]interface{}{
 		time.Date(1971, time.January, 1, 0, 0, 0, 0, time.UTC),
 		time.Date(1971, time.January, 1, 0, 0, 0, 0, time.UTC).In(location),
 	},
+	"big.Int": {
+		(*big.Int)(nil),
+		bigInt("-1000000000000000000000000000000"),
+		bigInt("-18446744073709551616"),
+		bigInt("-18446744073709551615"),
+		bigInt("-256"),
+		bigInt("-255"),
+		bigInt("-1"),
+		bigInt("0"),
+		bigInt("1"),
+		bigInt("255"),
+		bigInt("256"),
+		bigInt("18446744073709551615"),
+		bigInt("18446744073709551616"),
+		bigInt("1000000000000000000000000000000"),
+	},
+	"big.Float": {
+		(*big.Float)(nil),
+		bigFloat("-Inf"),
+		bigFloat("-1e100"),
+		bigFloat("-256"),
+		bigFloat("-255.5"),
+		bigFloat("-1"),
+		bigFloat("-0.5"),
+		bigFloat("-0.1"),
+		bigFloat("-1e-100"),
+		bigFloat("0"),
+		bigFloat("1e-100"),
+		bigFloat("0.1"),
+		bigFloat("0.5"),
+		bigFloat("1"),
+		bigFloat("1.0000000000000000000000000000001"),
+		bigFloat("255.5"),
+		bigFloat("256"),
+		bigFloat("257"),
+		bigFloat("1e100"),
+		bigFloat("+Inf"),
+	},
+	"Decimal": {
+		bytesort.Decimal("-1e10"),
+		bytesort.Decimal("-100"),
+		bytesort.Decimal("-99.99"),
+		bytesort.Decimal("-10"),
+		bytesort.Decimal("-9"),
+		bytesort.Decimal("-1.5"),
+		bytesort.Decimal("-1.05"),
+		bytesort.Decimal("-1"),
+		bytesort.Decimal("-0.001"),
+		bytesort.Decimal("0"),
+		bytesort.Decimal("0.001"),
+		bytesort.Decimal("0.01"),
+		bytesort.Decimal("1"),
+		bytesort.Decimal("1.05"),
+		bytesort.Decimal("1.5"),
+		bytesort.Decimal("9"),
+		bytesort.Decimal("10"),
+		bytesort.Decimal("99.99"),
+		bytesort.Decimal("100"),
+		bytesort.Decimal("1e10"),
+	},
 }
 
 func TestEncode_sortability(t *testing.T) {
 	for typ, values := range sortTests {
 		t.Run(typ, func(t *testing.T) {
-			testEncodeSortability(t, values)
+			testEncodeSortability(t, values, variableLength[typ])
 		})
 	}
 }
 
-func testEncodeSortability(t *testing.T, values []interface{}) {
+func testEncodeSortability(t *testing.T, values []interface{}, varLen bool) {
 	exp := make([][]byte, 0, len(values))
 	act := make([][]byte, 0, len(values))
 	for _, v := range values {
@@ -224,7 +367,7 @@ func testEncodeSortability(t *testing.T, values []interface{}) {
 		exp = append(exp, b)
 		act = append(act, b)
 	}
-	if reflect.TypeOf(values[0]).Kind() != reflect.String {
+	if !varLen {
 		for i := 1; i < len(act); i++ {
 			if len(act[i-1]) == len(act[i]) {
 				continue
@@ -272,6 +415,67 @@ func testEncode(t *testing.T, values []interface{}) {
 	internal.Gold(t, act.Bytes(), *update)
 }
 
+func TestAppend(t *testing.T) {
+	prefix := []byte("prefix")
+	for typ, values := range sortTests {
+		t.Run(typ, func(t *testing.T) {
+			for _, v := range values {
+				exp, err := bytesort.Encode(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dst := append([]byte{}, prefix...)
+				act, err := bytesort.Append(dst, v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(act[:len(prefix)], prefix) {
+					t.Errorf("expected prefix %q to be kept, got %q", prefix, act[:len(prefix)])
+				}
+				if !bytes.Equal(act[len(prefix):], exp) {
+					t.Errorf("%v: expected % x, got % x", v, exp, act[len(prefix):])
+				}
+			}
+		})
+	}
+}
+
+func TestEncode_equalNumbers(t *testing.T) {
+	equal := [][]interface{}{
+		{bytesort.Decimal("1.5"), bytesort.Decimal("1.50"), bytesort.Decimal("+01.5"), bytesort.Decimal("15e-1"), bytesort.Decimal("0.15E1")},
+		{bytesort.Decimal("0"), bytesort.Decimal("-0"), bytesort.Decimal("0.000"), bytesort.Decimal("0e5")},
+		{bytesort.Decimal("100"), bytesort.Decimal("1e2"), bytesort.Decimal("100.0")},
+		{bigFloat("0"), new(big.Float).Neg(bigFloat("0"))},
+		{bigFloat("1.5"), big.NewFloat(1.5), new(big.Float).SetPrec(1000).SetFloat64(1.5)},
+	}
+	for _, values := range equal {
+		exp, err := bytesort.Encode(values[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range values[1:] {
+			act, err := bytesort.Encode(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(act, exp) {
+				t.Errorf("expected %v to be encoded like %v: got % x, want % x", v, values[0], act, exp)
+			}
+		}
+	}
+}
+
+func TestAppend_error(t *testing.T) {
+	dst := []byte("keep")
+	act, err := bytesort.Append(dst, []string{})
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+	if !bytes.Equal(act, dst) {
+		t.Errorf("expected dst to be returned unchanged, got %q", act)
+	}
+}
+
 func TestEncode_fixedLengthExceptForStrings(t *testing.T) {
 	for typ, values := range sortTests {
 		t.Run(typ, func(t *testing.T) {
@@ -283,7 +487,7 @@ func TestEncode_fixedLengthExceptForStrings(t *testing.T) {
 				}
 				if length == -1 {
 					length = len(b)
-				} else if length != len(b) && typ != "string" {
+				} else if length != len(b) && !variableLength[typ] {
 					t.Errorf(
 						"expected fixed length for type %s: length %d of %q is different from the first value's length %d",
 						typ,
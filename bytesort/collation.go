@@ -0,0 +1,101 @@
+package bytesort
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Collation transforms strings before encoding them.
+//
+// Strings that only differ in ways ignored by the collation result in the same
+// encoding. This allows case-insensitive or accent-insensitive comparison.
+//
+// Flags can be combined, e.g. Fold|NFKC. The transformations are applied in
+// the order StripAccents, Fold, NFC and NFKC.
+type Collation uint8
+
+const (
+	// Fold applies full Unicode case folding, e.g. "Straße" and "STRASSE" are
+	// encoded the same.
+	Fold Collation = 1 << iota
+	// NFC normalises to the canonical composed form, e.g. "\u00e9" and
+	// "e\u0301" are encoded the same.
+	NFC
+	// NFKC normalises to the compatibility composed form, e.g. "ﬁ" and "fi"
+	// are encoded the same.
+	NFKC
+	// StripAccents removes diacritical marks, e.g. "é" is encoded as "e".
+	StripAccents
+)
+
+var collationNames = []struct {
+	c    Collation
+	name string
+}{
+	{StripAccents, "unaccent"},
+	{Fold, "fold"},
+	{NFC, "nfc"},
+	{NFKC, "nfkc"},
+}
+
+// ParseCollation returns the collation flag with the given name.
+//
+// Valid names are "fold", "nfc", "nfkc" and "unaccent".
+func ParseCollation(name string) (Collation, error) {
+	for _, cn := range collationNames {
+		if cn.name == name {
+			return cn.c, nil
+		}
+	}
+	return 0, fmt.Errorf("bytesort: unknown collation %q", name)
+}
+
+// String returns the names of all flags joined by "+".
+func (c Collation) String() string {
+	var names []string
+	for _, cn := range collationNames {
+		if c&cn.c != 0 {
+			names = append(names, cn.name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// Apply returns s transformed by the collation.
+func (c Collation) Apply(s string) string {
+	if c&StripAccents != 0 {
+		t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		s, _, _ = transform.String(t, s)
+	}
+	if c&Fold != 0 {
+		s = cases.Fold().String(s)
+	}
+	if c&NFC != 0 {
+		s = norm.NFC.String(s)
+	}
+	if c&NFKC != 0 {
+		s = norm.NFKC.String(s)
+	}
+	return s
+}
+
+// AppendString appends the encoding of a collated string to dst.
+func (c Collation) AppendString(dst []byte, s string) []byte {
+	return AppendString(dst, c.Apply(s))
+}
+
+// Append encodes v like the package level Append function.
+//
+// Strings are transformed by the collation first. Other types are not affected.
+func (c Collation) Append(dst []byte, v interface{}) ([]byte, error) {
+	if s, ok := v.(string); ok {
+		return c.AppendString(dst, s), nil
+	}
+	return Append(dst, v)
+}
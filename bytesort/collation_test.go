@@ -0,0 +1,78 @@
+package bytesort_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nochso/bolster/bytesort"
+)
+
+var collationTests = []struct {
+	c     bytesort.Collation
+	equal []string
+	other string
+}{
+	{bytesort.Fold, []string{"alice@example.com", "Alice@Example.com", "ALICE@EXAMPLE.COM"}, "bob@example.com"},
+	{bytesort.Fold, []string{"Straße", "STRASSE", "strasse"}, "strase"},
+	{bytesort.NFC, []string{"\u00e9", "e\u0301"}, "e"},
+	{bytesort.NFKC, []string{"ﬁ", "fi"}, "f"},
+	{bytesort.StripAccents, []string{"\u00e9", "e", "e\u0301"}, "E"},
+	{bytesort.Fold | bytesort.StripAccents, []string{"Élan", "elan", "ÉLAN"}, "elan "},
+}
+
+func TestCollation_Append(t *testing.T) {
+	for _, tc := range collationTests {
+		t.Run(tc.c.String(), func(t *testing.T) {
+			exp := tc.c.AppendString(nil, tc.equal[0])
+			for _, s := range tc.equal[1:] {
+				act, err := tc.c.Append(nil, s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(act, exp) {
+					t.Errorf("expected %q to be encoded like %q: got % x, want % x", s, tc.equal[0], act, exp)
+				}
+			}
+			other := tc.c.AppendString(nil, tc.other)
+			if bytes.Equal(other, exp) {
+				t.Errorf("expected %q to be encoded different from %q", tc.other, tc.equal[0])
+			}
+		})
+	}
+}
+
+func TestCollation_Append_nonString(t *testing.T) {
+	exp, err := bytesort.Encode(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, err := bytesort.Fold.Append(nil, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(act, exp) {
+		t.Errorf("expected non-strings to be unaffected: got % x, want % x", act, exp)
+	}
+}
+
+func TestParseCollation(t *testing.T) {
+	for _, name := range []string{"fold", "nfc", "nfkc", "unaccent"} {
+		c, err := bytesort.ParseCollation(name)
+		if err != nil {
+			t.Error(err)
+		}
+		if c.String() != name {
+			t.Errorf("expected %q, got %q", name, c.String())
+		}
+	}
+	_, err := bytesort.ParseCollation("upper")
+	if err == nil {
+		t.Error("expected error, got nil")
+	} else {
+		t.Log(err)
+	}
+	c := bytesort.Fold | bytesort.NFKC
+	if c.String() != "fold+nfkc" {
+		t.Errorf("expected \"fold+nfkc\", got %q", c.String())
+	}
+}
@@ -0,0 +1,344 @@
+package bytesort_test
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/nochso/bolster/bytesort"
+)
+
+// Ordering invariant: for values a and b of the same type, the bytewise
+// comparison of their encodings equals the comparison of the values.
+
+func encodingOrder(t *testing.T, a, b interface{}) int {
+	ea, err := bytesort.Encode(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eb, err := bytesort.Encode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Compare(ea, eb)
+}
+
+func checkOrder(t *testing.T, a, b interface{}, exp int) bool {
+	act := encodingOrder(t, a, b)
+	if act != exp {
+		t.Errorf("%T: expected order %d, got %d for %v and %v", a, exp, act, a, b)
+		return false
+	}
+	return true
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// cmpFloat orders NaN after +Inf and treats -0 and +0 as equal.
+func cmpFloat(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return 1
+	case math.IsNaN(b):
+		return -1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// cmpString orders the empty string like "\x00" as both share an encoding.
+func cmpString(a, b string) int {
+	if a == "" {
+		a = "\x00"
+	}
+	if b == "" {
+		b = "\x00"
+	}
+	return strings.Compare(a, b)
+}
+
+func cmpTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// newTime returns a time within ±10000 years of the epoch in a zone derived
+// from offset.
+func newTime(sec int64, nsec uint32, offset int32) time.Time {
+	const maxSec = 10000 * 365 * 24 * 60 * 60
+	zone := time.FixedZone("", int(offset%(14*60*60)))
+	return time.Unix(sec%maxSec, int64(nsec%1e9)).In(zone)
+}
+
+var specialFloats = []float64{
+	math.Inf(-1),
+	-math.MaxFloat64,
+	-1,
+	-math.SmallestNonzeroFloat64,
+	math.Copysign(0, -1),
+	0,
+	math.SmallestNonzeroFloat64,
+	1,
+	math.MaxFloat64,
+	math.Inf(1),
+	math.NaN(),
+	-math.NaN(),
+	math.Float64frombits(0x7ff0000000000001), // signalling NaN
+	math.Float64frombits(0xfff8000000000001), // negative NaN with payload
+}
+
+var specialInts = []int64{math.MinInt64, math.MinInt64 + 1, math.MinInt32, -1, 0, 1, math.MaxInt32, math.MaxInt64 - 1, math.MaxInt64}
+
+func TestOrder_floatSpecials(t *testing.T) {
+	for _, a := range specialFloats {
+		for _, b := range specialFloats {
+			exp := cmpFloat(a, b)
+			checkOrder(t, a, b, exp)
+			checkOrder(t, float32(a), float32(b), cmpFloat(float64(float32(a)), float64(float32(b))))
+			checkOrder(t, big.NewInt(int64(a)), big.NewInt(int64(b)), big.NewInt(int64(a)).Cmp(big.NewInt(int64(b))))
+			if !math.IsNaN(a) && !math.IsNaN(b) {
+				checkOrder(t, big.NewFloat(a), big.NewFloat(b), exp)
+			}
+		}
+	}
+}
+
+func TestOrder_intSpecials(t *testing.T) {
+	for _, a := range specialInts {
+		for _, b := range specialInts {
+			exp := cmpInt(a, b)
+			checkOrder(t, a, b, exp)
+			checkOrder(t, int(a), int(b), exp)
+			checkOrder(t, int32(a), int32(b), cmpInt(int64(int32(a)), int64(int32(b))))
+			checkOrder(t, int16(a), int16(b), cmpInt(int64(int16(a)), int64(int16(b))))
+			checkOrder(t, int8(a), int8(b), cmpInt(int64(int8(a)), int64(int8(b))))
+			checkOrder(t, uint64(a), uint64(b), cmpUint(uint64(a), uint64(b)))
+			checkOrder(t, big.NewInt(a), big.NewInt(b), exp)
+		}
+	}
+}
+
+func TestOrder_timeZones(t *testing.T) {
+	instant := time.Date(2017, time.June, 1, 12, 0, 0, 500, time.UTC)
+	zones := []*time.Location{
+		time.UTC,
+		time.FixedZone("UTC+14", 14*60*60),
+		time.FixedZone("UTC-12", -12*60*60),
+		time.FixedZone("UTC+05:45", 5*60*60+45*60),
+		time.FixedZone("LMT", -(4*60*60 + 56*60 + 2)), // offset with seconds
+	}
+	for _, za := range zones {
+		for _, zb := range zones {
+			a := instant.In(za)
+			checkOrder(t, a, instant.In(zb), 0)
+			checkOrder(t, a, instant.Add(time.Nanosecond).In(zb), -1)
+			checkOrder(t, a, instant.Add(-time.Nanosecond).In(zb), 1)
+		}
+	}
+	// monotonic clock readings must not affect the encoding
+	now := time.Now()
+	checkOrder(t, now, now.Round(0), 0)
+}
+
+func TestOrder_quick(t *testing.T) {
+	props := map[string]interface{}{
+		"int8":    func(a, b int8) bool { return checkOrder(t, a, b, cmpInt(int64(a), int64(b))) },
+		"int16":   func(a, b int16) bool { return checkOrder(t, a, b, cmpInt(int64(a), int64(b))) },
+		"int32":   func(a, b int32) bool { return checkOrder(t, a, b, cmpInt(int64(a), int64(b))) },
+		"int64":   func(a, b int64) bool { return checkOrder(t, a, b, cmpInt(a, b)) },
+		"int":     func(a, b int) bool { return checkOrder(t, a, b, cmpInt(int64(a), int64(b))) },
+		"uint8":   func(a, b uint8) bool { return checkOrder(t, a, b, cmpUint(uint64(a), uint64(b))) },
+		"uint16":  func(a, b uint16) bool { return checkOrder(t, a, b, cmpUint(uint64(a), uint64(b))) },
+		"uint32":  func(a, b uint32) bool { return checkOrder(t, a, b, cmpUint(uint64(a), uint64(b))) },
+		"uint64":  func(a, b uint64) bool { return checkOrder(t, a, b, cmpUint(a, b)) },
+		"uint":    func(a, b uint) bool { return checkOrder(t, a, b, cmpUint(uint64(a), uint64(b))) },
+		"float32": func(a, b float32) bool { return checkOrder(t, a, b, cmpFloat(float64(a), float64(b))) },
+		"float64": func(a, b float64) bool { return checkOrder(t, a, b, cmpFloat(a, b)) },
+		"bool": func(a, b bool) bool {
+			ai, bi := int64(0), int64(0)
+			if a {
+				ai = 1
+			}
+			if b {
+				bi = 1
+			}
+			return checkOrder(t, a, b, cmpInt(ai, bi))
+		},
+		"string": func(a, b string) bool { return checkOrder(t, a, b, cmpString(a, b)) },
+		"time.Time": func(as, bs int64, an, bn uint32, az, bz int32) bool {
+			a, b := newTime(as, an, az), newTime(bs, bn, bz)
+			return checkOrder(t, a, b, cmpTime(a, b))
+		},
+		"big.Int": func(a, b []byte, an, bn bool) bool {
+			ai, bi := new(big.Int).SetBytes(a), new(big.Int).SetBytes(b)
+			if an {
+				ai.Neg(ai)
+			}
+			if bn {
+				bi.Neg(bi)
+			}
+			return checkOrder(t, ai, bi, ai.Cmp(bi))
+		},
+		"big.Float": func(a, b float64, ae, be int8) bool {
+			if math.IsNaN(a) || math.IsNaN(b) {
+				return true
+			}
+			af := new(big.Float).SetMantExp(big.NewFloat(a), int(ae))
+			bf := new(big.Float).SetMantExp(big.NewFloat(b), int(be))
+			return checkOrder(t, af, bf, af.Cmp(bf))
+		},
+		"Decimal": func(am, bm int64, ae, be int8) bool {
+			a, b := newDecimal(am, ae), newDecimal(bm, be)
+			return checkOrder(t, a, b, cmpDecimal(t, a, b))
+		},
+	}
+	for name, prop := range props {
+		t.Run(name, func(t *testing.T) {
+			err := quick.Check(prop, &quick.Config{MaxCount: 1000})
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// newDecimal formats m × 10^e in plain or scientific notation.
+func newDecimal(m int64, e int8) bytesort.Decimal {
+	s := big.NewInt(m).String()
+	if e%2 == 0 {
+		return bytesort.Decimal(s + "e" + big.NewInt(int64(e)).String())
+	}
+	r := new(big.Rat).SetFrac(big.NewInt(m), big.NewInt(1))
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs8(e))), nil)
+	if e < 0 {
+		r.Quo(r, new(big.Rat).SetInt(scale))
+	} else {
+		r.Mul(r, new(big.Rat).SetInt(scale))
+	}
+	return bytesort.Decimal(r.FloatString(int(abs8(e))))
+}
+
+func abs8(e int8) int16 {
+	if e < 0 {
+		return -int16(e)
+	}
+	return int16(e)
+}
+
+func cmpDecimal(t *testing.T, a, b bytesort.Decimal) int {
+	ar, ok := new(big.Rat).SetString(string(a))
+	if !ok {
+		t.Fatalf("invalid decimal %q", a)
+	}
+	br, ok := new(big.Rat).SetString(string(b))
+	if !ok {
+		t.Fatalf("invalid decimal %q", b)
+	}
+	return ar.Cmp(br)
+}
+
+func FuzzOrder_int64(f *testing.F) {
+	for _, a := range specialInts {
+		f.Add(a, int64(0))
+	}
+	f.Fuzz(func(t *testing.T, a, b int64) {
+		checkOrder(t, a, b, cmpInt(a, b))
+		checkOrder(t, big.NewInt(a), big.NewInt(b), cmpInt(a, b))
+	})
+}
+
+func FuzzOrder_uint64(f *testing.F) {
+	f.Add(uint64(0), uint64(math.MaxUint64))
+	f.Fuzz(func(t *testing.T, a, b uint64) {
+		checkOrder(t, a, b, cmpUint(a, b))
+	})
+}
+
+func FuzzOrder_float64(f *testing.F) {
+	for _, a := range specialFloats {
+		f.Add(a, 0.0)
+	}
+	f.Fuzz(func(t *testing.T, a, b float64) {
+		checkOrder(t, a, b, cmpFloat(a, b))
+		if !math.IsNaN(a) && !math.IsNaN(b) {
+			checkOrder(t, big.NewFloat(a), big.NewFloat(b), cmpFloat(a, b))
+		}
+	})
+}
+
+func FuzzOrder_float32(f *testing.F) {
+	for _, a := range specialFloats {
+		f.Add(float32(a), float32(0))
+	}
+	f.Fuzz(func(t *testing.T, a, b float32) {
+		checkOrder(t, a, b, cmpFloat(float64(a), float64(b)))
+	})
+}
+
+func FuzzOrder_string(f *testing.F) {
+	f.Add("", "\x00")
+	f.Add("a", "a\x00")
+	f.Add("Aaron", "aaron")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		checkOrder(t, a, b, cmpString(a, b))
+	})
+}
+
+func FuzzOrder_time(f *testing.F) {
+	f.Add(int64(0), uint32(0), int32(0), int64(0), uint32(0), int32(-4*60*60))
+	f.Add(int64(-1), uint32(999999999), int32(0), int64(0), uint32(0), int32(0))
+	f.Fuzz(func(t *testing.T, as int64, an uint32, az int32, bs int64, bn uint32, bz int32) {
+		a, b := newTime(as, an, az), newTime(bs, bn, bz)
+		checkOrder(t, a, b, cmpTime(a, b))
+	})
+}
+
+// reDecimal limits fuzzed decimals to exponents big.Rat can handle quickly.
+var reDecimal = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]{1,3})?$`)
+
+func FuzzOrder_decimal(f *testing.F) {
+	f.Add("1.5", "1.50")
+	f.Add("-0", "0.000")
+	f.Add("99.99", "100")
+	f.Add("-1e-3", "-.001")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		if !reDecimal.MatchString(a) || !reDecimal.MatchString(b) {
+			return
+		}
+		da, db := bytesort.Decimal(a), bytesort.Decimal(b)
+		checkOrder(t, da, db, cmpDecimal(t, da, db))
+	})
+}
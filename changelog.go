@@ -0,0 +1,226 @@
+package bolster
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/boltdb/bolt"
+	"github.com/nochso/bolster/bytesort"
+)
+
+var (
+	bktNameChangelog = []byte("changelog")
+	// keyChangelogKeep stores the amount of entries to keep. Unlike the keys of
+	// entries it is not 8 bytes long.
+	keyChangelogKeep = []byte("keep")
+)
+
+// LogEntry is a change read from the changelog.
+//
+// Old and New are pointers to the item before and after the change, like the
+// values of Change. Struct is the full name of the item's type.
+type LogEntry struct {
+	Seq    uint64
+	Type   ChangeType
+	Struct string
+	Old    interface{}
+	New    interface{}
+}
+
+// logRecord is the stored form of a LogEntry.
+type logRecord struct {
+	Type   ChangeType
+	Struct string
+	Old    []byte `json:",omitempty"`
+	New    []byte `json:",omitempty"`
+}
+
+// EnableChangelog records every change of items in a changelog.
+//
+// Entries are written within the transaction of the change and are numbered
+// by a sequence that increases monotonically, even after truncating the log.
+// Consumers can resume reading where they left off using ChangesSince.
+//
+// If keep is greater than zero, only the latest keep entries are retained.
+// Otherwise entries are kept until TruncateChangelog is called.
+//
+// The changelog and keep are stored in the database. Changes are logged by
+// every Store opening it, whether EnableChangelog is called or not. Calling it
+// again replaces keep.
+func (s *Store) EnableChangelog(keep int) error {
+	if keep < 0 {
+		keep = 0
+	}
+	return s.Write(func(tx *Tx) error {
+		bkt, err := tx.btx.CreateBucketIfNotExists(bktNameChangelog)
+		if err != nil {
+			return err
+		}
+		return bkt.Put(keyChangelogKeep, bytesort.AppendUint64(nil, uint64(keep)))
+	})
+}
+
+// ChangesSince calls fn for each changelog entry with a sequence greater than
+// seq, in order of their sequence.
+//
+// fn is called within a read-only transaction. Iteration stops at the first
+// error returned by fn, which is then returned from ChangesSince. Items of
+// types that are not registered can not be decoded and cause an error.
+func (s *Store) ChangesSince(seq uint64, fn func(LogEntry) error) error {
+	return s.Read(func(tx *Tx) error {
+		bkt := tx.btx.Bucket(bktNameChangelog)
+		if bkt == nil {
+			return errors.New("changelog is not enabled")
+		}
+		if seq == math.MaxUint64 {
+			return nil
+		}
+		c := bkt.Cursor()
+		for k, v := c.Seek(bytesort.AppendUint64(nil, seq+1)); k != nil; k, v = c.Next() {
+			if !isLogKey(k) {
+				continue
+			}
+			e, err := s.decodeLogEntry(k, v)
+			if err != nil {
+				return err
+			}
+			err = fn(e)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TruncateChangelog deletes all changelog entries with a sequence less than
+// or equal to seq, e.g. once they have been processed.
+func (s *Store) TruncateChangelog(seq uint64) error {
+	return s.Write(func(tx *Tx) error {
+		bkt := tx.btx.Bucket(bktNameChangelog)
+		if bkt == nil {
+			return errors.New("changelog is not enabled")
+		}
+		return tx.truncateChangelog(bkt, seq)
+	})
+}
+
+func (tx *Tx) truncateChangelog(bkt *bolt.Bucket, seq uint64) error {
+	var keys [][]byte
+	c := bkt.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		if !isLogKey(k) {
+			continue
+		}
+		if decodeLogSeq(k) > seq {
+			break
+		}
+		keys = append(keys, cloneBytes(k))
+	}
+	for _, k := range keys {
+		err := tx.delete(bkt, k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logChange appends a change to the changelog if it exists.
+func (tx *Tx) logChange(st structType, ct ChangeType, old, item reflect.Value) error {
+	bkt := tx.btx.Bucket(bktNameChangelog)
+	if bkt == nil {
+		return nil
+	}
+	r := logRecord{Type: ct, Struct: st.String()}
+	var err error
+	if old.IsValid() {
+		r.Old, err = tx.store.codec.Marshal(old.Interface())
+		if err != nil {
+			return err
+		}
+	}
+	if item.IsValid() {
+		r.New, err = tx.store.codec.Marshal(item.Interface())
+		if err != nil {
+			return err
+		}
+	}
+	b, err := tx.store.codec.Marshal(r)
+	if err != nil {
+		return err
+	}
+	seq, err := tx.nextSequence(bkt)
+	if err != nil {
+		return err
+	}
+	err = tx.put(bkt, bytesort.AppendUint64(make([]byte, 0, 8), seq), b)
+	if err != nil {
+		return err
+	}
+	if keep := decodeLogKeep(bkt); keep > 0 && seq > keep {
+		return tx.truncateChangelog(bkt, seq-keep)
+	}
+	return nil
+}
+
+func (s *Store) decodeLogEntry(k, v []byte) (LogEntry, error) {
+	e := LogEntry{Seq: decodeLogSeq(k)}
+	r := logRecord{}
+	err := s.codec.Unmarshal(v, &r)
+	if err != nil {
+		return e, err
+	}
+	e.Type, e.Struct = r.Type, r.Struct
+	var st structType
+	found := false
+	for _, t := range s.types {
+		if t.String() == r.Struct {
+			st, found = t, true
+			break
+		}
+	}
+	if !found && (r.Old != nil || r.New != nil) {
+		return e, fmt.Errorf("changelog entry %d: unregistered struct: %s", e.Seq, r.Struct)
+	}
+	if r.Old != nil {
+		old := reflect.New(st.Type)
+		err = s.codec.Unmarshal(r.Old, old.Interface())
+		if err != nil {
+			return e, err
+		}
+		e.Old = old.Interface()
+	}
+	if r.New != nil {
+		item := reflect.New(st.Type)
+		err = s.codec.Unmarshal(r.New, item.Interface())
+		if err != nil {
+			return e, err
+		}
+		e.New = item.Interface()
+	}
+	return e, nil
+}
+
+// isLogKey returns true if k is the key of a changelog entry.
+func isLogKey(k []byte) bool {
+	return len(k) == 8
+}
+
+// decodeLogKeep returns the amount of changelog entries to keep, zero meaning
+// all of them.
+func decodeLogKeep(bkt *bolt.Bucket) uint64 {
+	b := bkt.Get(keyChangelogKeep)
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// decodeLogSeq returns the sequence of a changelog key.
+func decodeLogSeq(k []byte) uint64 {
+	return binary.BigEndian.Uint64(k)
+}
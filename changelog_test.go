@@ -0,0 +1,173 @@
+package bolster_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+// readChangelog returns the sequences and types of changelog entries after seq.
+func readChangelog(t *testing.T, st *bolster.Store, seq uint64) ([]uint64, []bolster.ChangeType) {
+	var seqs []uint64
+	var types []bolster.ChangeType
+	err := st.ChangesSince(seq, func(e bolster.LogEntry) error {
+		seqs = append(seqs, e.Seq)
+		types = append(types, e.Type)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seqs, types
+}
+
+func TestStore_ChangesSince(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.EnableChangelog(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.Insert(&structWithIDAndField{ID: 1, Name: "foo"})
+		tx.Update(&structWithIDAndField{ID: 1, Name: "bar"})
+		tx.Savepoint(func(tx *bolster.Tx) error {
+			tx.Insert(&structWithIDAndField{ID: 2})
+			return errors.New("failure")
+		})
+		return tx.Delete(&structWithIDAndField{ID: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Write(func(tx *bolster.Tx) error {
+		tx.Insert(&structWithIDAndField{ID: 3})
+		return errors.New("rolled back")
+	})
+
+	var entries []bolster.LogEntry
+	err = st.ChangesSince(0, func(e bolster.LogEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %v", len(entries), entries)
+	}
+	upd := entries[1]
+	if upd.Seq != 2 || upd.Type != bolster.ChangeUpdate ||
+		upd.Old.(*structWithIDAndField).Name != "foo" || upd.New.(*structWithIDAndField).Name != "bar" {
+		t.Errorf("unexpected update entry: %+v", upd)
+	}
+	if entries[2].Type != bolster.ChangeDelete || entries[2].New != nil {
+		t.Errorf("unexpected delete entry: %+v", entries[2])
+	}
+
+	// resume after the update
+	seqs, _ := readChangelog(t, st, 2)
+	if len(seqs) != 1 || seqs[0] != 3 {
+		t.Errorf("expected to resume at sequence 3, got %v", seqs)
+	}
+
+	err = st.TruncateChangelog(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Truncate(structWithIDAndField{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqs, types := readChangelog(t, st, 0)
+	if len(seqs) != 2 || seqs[0] != 3 || seqs[1] != 4 || types[1] != bolster.ChangeTruncate {
+		t.Errorf("expected sequences 3 and 4 after truncating, got %v %v", seqs, types)
+	}
+}
+
+func TestStore_EnableChangelog_keep(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithAutoincrement{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.EnableChangelog(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		err = st.Write(func(tx *bolster.Tx) error {
+			return tx.Insert(&structWithAutoincrement{})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	seqs, _ := readChangelog(t, st, 0)
+	if len(seqs) != 3 || seqs[0] != 3 || seqs[2] != 5 {
+		t.Errorf("expected the latest 3 entries, got %v", seqs)
+	}
+}
+
+func TestStore_ChangesSince_reopen(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.EnableChangelog(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(&structWithIDAndField{ID: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := st.Bolt().Path()
+	err = st.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err = bolster.Open(path, 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	err = st.Register(structWithIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the changelog and keep are stored, so EnableChangelog is not called again
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.Insert(&structWithIDAndField{ID: 2})
+		return tx.Insert(&structWithIDAndField{ID: 3})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqs, _ := readChangelog(t, st, 0)
+	if len(seqs) != 2 || seqs[0] != 2 || seqs[1] != 3 {
+		t.Errorf("expected changelog to continue after reopening, got %v", seqs)
+	}
+}
+
+func TestStore_ChangesSince_disabled(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.ChangesSince(0, func(bolster.LogEntry) error { return nil })
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
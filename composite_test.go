@@ -0,0 +1,135 @@
+package bolster_test
+
+import (
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithCompositeID struct {
+	TenantID int    `bolster:"id 0"`
+	Slug     string `bolster:"id 1"`
+	Title    string `bolster:"index"`
+}
+
+type structWithStringFirstCompositeID struct {
+	Slug     string `bolster:"id 0"`
+	Revision int    `bolster:"id 1"`
+}
+
+type structWithIncompleteCompositeID struct {
+	A int `bolster:"id 0"`
+	B int `bolster:"id 2"`
+}
+
+type structWithMixedCompositeID struct {
+	A int `bolster:"id"`
+	B int `bolster:"id 0"`
+}
+
+type structWithAutoincrementedCompositeID struct {
+	A int `bolster:"id 0,inc"`
+	B int `bolster:"id 1"`
+}
+
+func TestTx_compositeID(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithCompositeID{}, structWithStringFirstCompositeID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.InsertMany([]structWithCompositeID{
+			{1, "a", "first"},
+			{1, "b", "second"},
+			{2, "a", "third"},
+		})
+		tx.Update(&structWithCompositeID{1, "a", "updated"})
+		tx.Delete(&structWithCompositeID{TenantID: 1, Slug: "b"})
+		return tx.InsertMany([]structWithStringFirstCompositeID{{"a", 1}, {"a", 2}, {"b", 1}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	internal.GoldStore(t, st, *updateGold)
+	err = st.Read(func(tx *bolster.Tx) error {
+		item := &structWithCompositeID{}
+		err := tx.Get(item, bolster.Key{1, "a"})
+		if err != nil {
+			return err
+		}
+		if item.Title != "updated" {
+			t.Errorf("expected updated item, got %+v", item)
+		}
+		err = tx.Get(item, bolster.Key{1, "b"})
+		if e, ok := err.(bolster.Error); !ok || !e.IsNotFound() {
+			t.Errorf("expected ErrNotFound for deleted item, got %v", err)
+		}
+		for _, id := range []interface{}{1, []interface{}{1, "a"}, bolster.Key{1}, bolster.Key{1, "a", 1}, bolster.Key{"1", "a"}, bolster.Key{1, 1}} {
+			err = tx.Get(item, id)
+			if err == nil {
+				t.Errorf("expected error for ID %v, got nil", id)
+			}
+		}
+		other := &structWithStringFirstCompositeID{}
+		err = tx.Get(other, bolster.Key{"a", 2})
+		if err != nil {
+			return err
+		}
+		if other.Revision != 2 {
+			t.Errorf("expected revision 2, got %+v", other)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTx_compositeID_errors(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithCompositeID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(&structWithCompositeID{1, "a", "first"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := internal.DumpStore(st)
+	for name, fn := range map[string]func(tx *bolster.Tx) error{
+		"insert existing": func(tx *bolster.Tx) error {
+			return tx.Insert(&structWithCompositeID{1, "a", "again"})
+		},
+		"update missing": func(tx *bolster.Tx) error {
+			return tx.Update(&structWithCompositeID{1, "b", "missing"})
+		},
+		"patch ID field": func(tx *bolster.Tx) error {
+			return tx.Patch(&structWithCompositeID{}, bolster.Key{1, "a"}, map[string]interface{}{"Slug": "b"})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := st.Write(fn)
+			t.Log(err)
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+			assertSameDump(t, exp, internal.DumpStore(st))
+		})
+	}
+	item := &structWithCompositeID{}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Patch(item, bolster.Key{1, "a"}, map[string]interface{}{"Title": "patched"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.TenantID != 1 || item.Slug != "a" || item.Title != "patched" {
+		t.Errorf("expected patched item, got %+v", item)
+	}
+}
@@ -10,6 +10,12 @@ var (
 	ErrNotFound = errors.New("item not found")
 	// ErrBadTransaction occurs when a write-action is aborted early because of a faulty transaction.
 	ErrBadTransaction = errors.New("abort early: previous error causes transaction rollback")
+	// ErrVersionConflict occurs when updating an item whose version field does
+	// not match the stored item, i.e. it has been changed in the meantime.
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrManagedTx occurs when trying to commit or rollback a transaction
+	// managed by Store.Read or Store.Write.
+	ErrManagedTx = errors.New("managed transaction must not be committed or rolled back manually")
 )
 
 // Error combines error with context information.
@@ -19,9 +25,9 @@ type Error struct {
 	Err        error
 }
 
-// IsNotFound returns true if the inner error is ErrNotFound.
+// IsNotFound returns true if the inner error is or wraps ErrNotFound.
 func (e Error) IsNotFound() bool {
-	return e.Err == ErrNotFound
+	return errors.Is(e.Err, ErrNotFound)
 }
 
 // IsBadTransaction returns true if the inner error is ErrBadTransaction.
@@ -29,6 +35,16 @@ func (e Error) IsBadTransaction() bool {
 	return e.Err == ErrBadTransaction
 }
 
+// IsVersionConflict returns true if the inner error is ErrVersionConflict.
+func (e Error) IsVersionConflict() bool {
+	return errors.Is(e.Err, ErrVersionConflict)
+}
+
+// Unwrap returns the inner error.
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
 func newErrorFactory(a txAction, st ...structType) Error {
 	e := Error{Action: a}
 	if len(st) > 0 {
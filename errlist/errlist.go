@@ -50,6 +50,12 @@ func (e *Errors) ErrorOrNil() error {
 	}
 }
 
+// Unwrap returns all errors of the list.
+// Since Go 1.20 it allows using errors.Is and errors.As with the list.
+func (e *Errors) Unwrap() []error {
+	return e.errs
+}
+
 // HasError returns true if the list contains at least one error.
 func (e *Errors) HasError() bool {
 	return len(e.errs) > 0
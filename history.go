@@ -0,0 +1,217 @@
+package bolster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/nochso/bolster/bytesort"
+)
+
+var bktNameHistory = []byte("history")
+
+// Version is a previous state of an item.
+type Version struct {
+	Seq  uint64     // sequence of the version, unique per type
+	Time time.Time  // time the version was replaced
+	Type ChangeType // change that replaced the version
+	Item interface{}
+}
+
+// historyRecord is the stored form of a Version.
+type historyRecord struct {
+	Time time.Time
+	Type ChangeType
+	Item []byte
+}
+
+// EnableHistory keeps the previous versions of all items of the given types.
+//
+// Whenever an item is updated, upserted or deleted, its stored value is
+// written to the type's history within the same transaction. Truncating a
+// type also deletes its history. EnableHistory must not be called
+// concurrently with transactions.
+func (s *Store) EnableHistory(v ...interface{}) error {
+	for _, vv := range v {
+		t := reflect.TypeOf(vv)
+		if t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		st, ok := s.types[t]
+		if !ok {
+			return fmt.Errorf("unregistered struct: %v", t)
+		}
+		st.History = true
+		s.types[t] = st
+	}
+	return nil
+}
+
+// logHistory copies the stored item with the given ID to the history before
+// it is replaced by a change of type ct.
+func (tx *Tx) logHistory(st structType, idBytes []byte, ct ChangeType) error {
+	if !st.History {
+		return nil
+	}
+	b := tx.dataBkt(st).Get(idBytes)
+	if b == nil {
+		return nil
+	}
+	bkt, err := tx.createBucket(tx.btx.Bucket(st.FullName), bktNameHistory)
+	if err != nil {
+		return err
+	}
+	r := historyRecord{Time: tx.store.now(), Type: ct, Item: b}
+	rb, err := tx.store.codec.Marshal(r)
+	if err != nil {
+		return err
+	}
+	seq, err := tx.nextSequence(bkt)
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 0, len(idBytes)+8)
+	key = append(key, idBytes...)
+	key = bytesort.AppendUint64(key, seq)
+	return tx.put(bkt, key, rb)
+}
+
+// History returns all previous versions of the item of v's type with the
+// given ID, oldest first.
+//
+// Items of versions are pointers to structs of v's type. History must be
+// enabled using Store.EnableHistory. Composite IDs are passed as a Key.
+//
+// Items with non-integer IDs are mapped to internal IDs that are released on
+// deletion. Their history can not be listed after they have been deleted
+// permanently.
+func (tx *Tx) History(v interface{}, id interface{}) ([]Version, error) {
+	st, _, err := tx.validateStruct(v, history)
+	if err != nil {
+		return nil, tx.errf.with(err)
+	}
+	if !st.History {
+		return nil, tx.errf.with(errors.New("history is not enabled"))
+	}
+	values, err := st.idValues(id)
+	if err != nil {
+		return nil, tx.errf.with(err)
+	}
+	idBytes, err := st.ID.encode(tx, values, tx.idxBkt(st), history)
+	if err == ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, tx.errf.with(err)
+	}
+	bkt := tx.btx.Bucket(st.FullName).Bucket(bktNameHistory)
+	if bkt == nil {
+		return nil, nil
+	}
+	var versions []Version
+	c := bkt.Cursor()
+	for k, b := c.Seek(idBytes); k != nil && bytes.HasPrefix(k, idBytes); k, b = c.Next() {
+		ver, err := tx.decodeVersion(st, k[len(idBytes):], b)
+		if err != nil {
+			return nil, tx.errf.with(err)
+		}
+		versions = append(versions, ver)
+	}
+	return versions, nil
+}
+
+func (tx *Tx) decodeVersion(st structType, seq, b []byte) (Version, error) {
+	r := historyRecord{}
+	err := tx.store.codec.Unmarshal(b, &r)
+	if err != nil {
+		return Version{}, err
+	}
+	item, err := tx.decode(st, r.Item)
+	if err != nil {
+		return Version{}, err
+	}
+	return Version{Seq: decodeLogSeq(seq), Time: r.Time, Type: r.Type, Item: item.Addr().Interface()}, nil
+}
+
+// Revert saves a previous version of an item as its current state.
+// v must be a pointer to a struct with its ID set; the reverted item is
+// decoded into v. seq is the sequence of a version returned by History.
+//
+// The version is saved like calling Upsert with it, e.g. hooks and validation
+// apply. The version field, if any, is taken from the stored item so that
+// reverting does not cause a version conflict. Deleted items are recreated,
+// however soft deleted items must be restored first.
+//
+// Deleted items with non-integer or composite IDs have released their
+// internal ID, so their version is found by scanning the history of the type.
+func (tx *Tx) Revert(v interface{}, seq uint64) error {
+	st, rv, err := tx.validateStruct(v, revert)
+	if tx.errs.HasError() {
+		return tx.addErr(ErrBadTransaction)
+	}
+	if err != nil {
+		return tx.addErr(err)
+	}
+	idBytes, err := st.ID.encodeStruct(tx, rv, tx.idxBkt(st), revert)
+	if err == ErrNotFound {
+		// the internal ID of a deleted item has been released
+		idBytes = nil
+	} else if err != nil {
+		return tx.addErr(err)
+	}
+	var key, b []byte
+	bkt := tx.btx.Bucket(st.FullName).Bucket(bktNameHistory)
+	if bkt != nil && idBytes != nil {
+		key = bytesort.AppendUint64(cloneBytes(idBytes), seq)
+		b = bkt.Get(key)
+	} else if bkt != nil {
+		key, b = findVersion(bkt, seq)
+	}
+	if b == nil {
+		return tx.addErr(fmt.Errorf("version %d: %w", seq, ErrNotFound))
+	}
+	ver, err := tx.decodeVersion(st, key[len(key)-8:], b)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	item := reflect.ValueOf(ver.Item).Elem()
+	if idBytes == nil && !sameID(st, item, rv) {
+		return tx.addErr(fmt.Errorf("version %d: %w", seq, ErrNotFound))
+	}
+	if st.Version != -1 && idBytes != nil {
+		if cur := tx.dataBkt(st).Get(idBytes); cur != nil {
+			stored, err := tx.decode(st, cur)
+			if err != nil {
+				return tx.addErr(err)
+			}
+			item.Field(st.Version).Set(stored.Field(st.Version))
+		}
+	}
+	tx.setField(rv, item)
+	return tx.addErr(tx.save(st, rv, v, upsert))
+}
+
+// findVersion returns the key and stored version with the given sequence.
+// Sequences are unique per type, so at most one key ends with it.
+func findVersion(bkt *bolt.Bucket, seq uint64) ([]byte, []byte) {
+	c := bkt.Cursor()
+	for k, b := c.First(); k != nil; k, b = c.Next() {
+		if len(k) > 8 && decodeLogSeq(k[len(k)-8:]) == seq {
+			return k, b
+		}
+	}
+	return nil, nil
+}
+
+// sameID returns true if the structs a and b have the same ID.
+func sameID(st structType, a, b reflect.Value) bool {
+	av, bv := st.ID.values(a), st.ID.values(b)
+	for n := range av {
+		if av[n] != bv[n] {
+			return false
+		}
+	}
+	return true
+}
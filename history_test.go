@@ -0,0 +1,189 @@
+package bolster_test
+
+import (
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithHistory struct {
+	ID      int
+	Name    string `bolster:"index"`
+	Version int    `bolster:"version"`
+}
+
+type structWithStringIDAndHistory struct {
+	Key  string `bolster:"id"`
+	Name string `bolster:"index"`
+}
+
+// TestTx_history runs each test on a fresh store with history enabled,
+// holding two items with a few changes each.
+func TestTx_history(t *testing.T) {
+	tests := map[string]func(t *testing.T, st *bolster.Store){
+		"History": func(t *testing.T, st *bolster.Store) {
+			internal.GoldStore(t, st, *updateGold)
+			err := st.Read(func(tx *bolster.Tx) error {
+				versions, err := tx.History(&structWithHistory{}, 1)
+				if err != nil {
+					return err
+				}
+				exp := []struct {
+					name string
+					typ  bolster.ChangeType
+				}{
+					{"foo", bolster.ChangeUpdate},
+					{"bar", bolster.ChangeUpsert},
+				}
+				if len(versions) != len(exp) {
+					t.Fatalf("expected %d versions, got %d", len(exp), len(versions))
+				}
+				for i, ver := range versions {
+					item := ver.Item.(*structWithHistory)
+					if item.Name != exp[i].name || ver.Type != exp[i].typ || item.Version != i+1 {
+						t.Errorf("#%d: expected %q replaced by %v, got %+v", i, exp[i].name, exp[i].typ, ver)
+					}
+				}
+				versions, err = tx.History(&structWithHistory{}, 2)
+				if len(versions) != 1 || versions[0].Type != bolster.ChangeDelete {
+					t.Errorf("expected history of deleted item, got %+v", versions)
+				}
+				return err
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		},
+		"HistoryDisabled": func(t *testing.T, st *bolster.Store) {
+			err := st.Read(func(tx *bolster.Tx) error {
+				_, err := tx.History(&structWithIDAndField{}, 1)
+				return err
+			})
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+		},
+		"Revert": func(t *testing.T, st *bolster.Store) {
+			err := st.Write(func(tx *bolster.Tx) error {
+				versions, err := tx.History(&structWithHistory{}, 1)
+				if err != nil {
+					return err
+				}
+				item := &structWithHistory{ID: 1}
+				err = tx.Revert(item, versions[0].Seq)
+				if err != nil {
+					return err
+				}
+				if item.Name != "foo" || item.Version != 4 {
+					t.Errorf("expected first version to be saved as version 4, got %+v", item)
+				}
+				// deleted items are recreated
+				versions, err = tx.History(&structWithHistory{}, 2)
+				if err != nil {
+					return err
+				}
+				return tx.Revert(&structWithHistory{ID: 2}, versions[0].Seq)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Read(func(tx *bolster.Tx) error {
+				item := &structWithHistory{}
+				err := tx.Get(item, 2)
+				if item.Name != "other" {
+					t.Errorf("expected deleted item to be recreated, got %+v", item)
+				}
+				return err
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Revert(&structWithHistory{ID: 1}, 1000)
+			})
+			if e, ok := err.(bolster.Error); !ok || !e.IsNotFound() {
+				t.Errorf("expected ErrNotFound for unknown version, got %v", err)
+			}
+		},
+		"RevertDeletedStringID": func(t *testing.T, st *bolster.Store) {
+			var versions []bolster.Version
+			err := st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithStringIDAndHistory{Key: "a", Name: "foo"})
+				tx.Insert(&structWithStringIDAndHistory{Key: "b", Name: "other"})
+				tx.Update(&structWithStringIDAndHistory{Key: "a", Name: "bar"})
+				var err error
+				versions, err = tx.History(&structWithStringIDAndHistory{}, "a")
+				if err != nil {
+					return err
+				}
+				return tx.Delete(&structWithStringIDAndHistory{Key: "a"})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(versions) != 1 {
+				t.Fatalf("expected 1 version, got %d", len(versions))
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Revert(&structWithStringIDAndHistory{Key: "b"}, versions[0].Seq)
+			})
+			if e, ok := err.(bolster.Error); !ok || !e.IsNotFound() {
+				t.Errorf("expected ErrNotFound for version of another item, got %v", err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Revert(&structWithStringIDAndHistory{Key: "c"}, versions[0].Seq)
+			})
+			if e, ok := err.(bolster.Error); !ok || !e.IsNotFound() {
+				t.Errorf("expected ErrNotFound for version of another deleted item, got %v", err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Revert(&structWithStringIDAndHistory{Key: "a"}, versions[0].Seq)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Read(func(tx *bolster.Tx) error {
+				item := &structWithStringIDAndHistory{}
+				err := tx.Get(item, "a")
+				if item.Name != "foo" {
+					t.Errorf("expected deleted item to be recreated, got %+v", item)
+				}
+				return err
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			internal.SetTestClock(st)
+			err := st.Register(structWithHistory{}, structWithStringIDAndHistory{}, structWithIDAndField{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.EnableHistory(structWithHistory{}, structWithStringIDAndHistory{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				item := &structWithHistory{ID: 1, Name: "foo"}
+				tx.Insert(item)
+				item.Name = "bar"
+				tx.Update(item)
+				item.Name = "baz"
+				tx.Upsert(item)
+				tx.Insert(&structWithHistory{ID: 2, Name: "other"})
+				return tx.Delete(&structWithHistory{ID: 2})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st)
+		})
+	}
+}
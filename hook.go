@@ -0,0 +1,128 @@
+package bolster
+
+// Lifecycle hooks are optional interfaces implemented by registered structs.
+//
+// Hooks are called with the transaction of the action, so they may read or
+// write other items. Before hooks are called prior to any changes: returning
+// an error aborts the action and fails the transaction like any other failed
+// action. After hooks are called once the item and its index data have been
+// written. An error returned by an After hook also fails the transaction.
+//
+// Hooks are only found when the action is passed a pointer to the struct or
+// the hook has a value receiver.
+
+// BeforeInserter is called before an item is inserted.
+// The ID is not autoincremented yet.
+type BeforeInserter interface {
+	BeforeInsert(*Tx) error
+}
+
+// AfterInserter is called after an item has been inserted.
+type AfterInserter interface {
+	AfterInsert(*Tx) error
+}
+
+// BeforeUpdater is called before an item is updated.
+type BeforeUpdater interface {
+	BeforeUpdate(*Tx) error
+}
+
+// AfterUpdater is called after an item has been updated.
+type AfterUpdater interface {
+	AfterUpdate(*Tx) error
+}
+
+// BeforeUpserter is called before an item is upserted.
+type BeforeUpserter interface {
+	BeforeUpsert(*Tx) error
+}
+
+// AfterUpserter is called after an item has been upserted.
+type AfterUpserter interface {
+	AfterUpsert(*Tx) error
+}
+
+// BeforeDeleter is called before an existing item is deleted.
+// It is not called when the item does not exist.
+type BeforeDeleter interface {
+	BeforeDelete(*Tx) error
+}
+
+// AfterDeleter is called after an item has been deleted.
+type AfterDeleter interface {
+	AfterDelete(*Tx) error
+}
+
+// AfterGetter is called after an item has been decoded by Tx.Get or Tx.Each.
+type AfterGetter interface {
+	AfterGet(*Tx) error
+}
+
+// callBefore calls the Before hook of v matching the action, if any.
+func (tx *Tx) callBefore(v interface{}, action txAction) error {
+	var hook func(*Tx) error
+	switch action {
+	case insert:
+		if h, ok := v.(BeforeInserter); ok {
+			hook = h.BeforeInsert
+		}
+	case update:
+		if h, ok := v.(BeforeUpdater); ok {
+			hook = h.BeforeUpdate
+		}
+	case upsert:
+		if h, ok := v.(BeforeUpserter); ok {
+			hook = h.BeforeUpsert
+		}
+	case delete:
+		if h, ok := v.(BeforeDeleter); ok {
+			hook = h.BeforeDelete
+		}
+	}
+	return tx.callHook(hook)
+}
+
+// callAfter calls the After hook of v matching the action, if any.
+func (tx *Tx) callAfter(v interface{}, action txAction) error {
+	var hook func(*Tx) error
+	switch action {
+	case insert:
+		if h, ok := v.(AfterInserter); ok {
+			hook = h.AfterInsert
+		}
+	case update:
+		if h, ok := v.(AfterUpdater); ok {
+			hook = h.AfterUpdate
+		}
+	case upsert:
+		if h, ok := v.(AfterUpserter); ok {
+			hook = h.AfterUpsert
+		}
+	case delete:
+		if h, ok := v.(AfterDeleter); ok {
+			hook = h.AfterDelete
+		}
+	case get:
+		if h, ok := v.(AfterGetter); ok {
+			hook = h.AfterGet
+		}
+	}
+	return tx.callHook(hook)
+}
+
+// callHook calls hook while keeping the error context of the current action.
+//
+// Hooks may run other actions which fail the transaction without the hook
+// returning an error. The current action is aborted in that case.
+func (tx *Tx) callHook(hook func(*Tx) error) error {
+	if hook == nil {
+		return nil
+	}
+	errf := tx.errf
+	err := hook(tx)
+	tx.errf = errf
+	if err == nil && tx.errs.HasError() {
+		err = ErrBadTransaction
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package bolster_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithHooks struct {
+	ID    int `bolster:"inc"`
+	Name  string
+	calls []string
+	fail  string
+}
+
+func (s *structWithHooks) hook(name string) error {
+	s.calls = append(s.calls, name)
+	if s.fail == name {
+		return errors.New(name + " failed")
+	}
+	return nil
+}
+
+func (s *structWithHooks) BeforeInsert(tx *bolster.Tx) error { return s.hook("BeforeInsert") }
+func (s *structWithHooks) AfterInsert(tx *bolster.Tx) error  { return s.hook("AfterInsert") }
+func (s *structWithHooks) BeforeUpdate(tx *bolster.Tx) error { return s.hook("BeforeUpdate") }
+func (s *structWithHooks) AfterUpdate(tx *bolster.Tx) error  { return s.hook("AfterUpdate") }
+func (s *structWithHooks) BeforeUpsert(tx *bolster.Tx) error { return s.hook("BeforeUpsert") }
+func (s *structWithHooks) AfterUpsert(tx *bolster.Tx) error  { return s.hook("AfterUpsert") }
+func (s *structWithHooks) BeforeDelete(tx *bolster.Tx) error { return s.hook("BeforeDelete") }
+func (s *structWithHooks) AfterDelete(tx *bolster.Tx) error  { return s.hook("AfterDelete") }
+func (s *structWithHooks) AfterGet(tx *bolster.Tx) error     { return s.hook("AfterGet") }
+
+// structWithCounter keeps track of how many structWithHooks were inserted.
+type structWithCounter struct {
+	ID    int
+	Count int
+}
+
+type structWithDenormalisingHook struct {
+	ID int `bolster:"inc"`
+}
+
+func (s *structWithDenormalisingHook) AfterInsert(tx *bolster.Tx) error {
+	c := &structWithCounter{ID: 1}
+	err := tx.Get(c, 1)
+	if err != nil && !err.(bolster.Error).IsNotFound() {
+		return err
+	}
+	c.Count++
+	return tx.Upsert(c)
+}
+
+func TestTx_hooks(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithHooks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &structWithHooks{Name: "foo"}
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.Insert(item)
+		tx.Update(item)
+		tx.Upsert(item)
+		tx.Delete(item)
+		tx.Delete(item) // does not exist anymore
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{
+		"BeforeInsert", "AfterInsert",
+		"BeforeUpdate", "AfterUpdate",
+		"BeforeUpsert", "AfterUpsert",
+		"BeforeDelete", "AfterDelete",
+	}
+	if !reflect.DeepEqual(item.calls, exp) {
+		t.Errorf("expected calls %v, got %v", exp, item.calls)
+	}
+
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(&structWithHooks{Name: "bar"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Read(func(tx *bolster.Tx) error {
+		item := &structWithHooks{}
+		err := tx.Get(item, 2)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(item.calls, []string{"AfterGet"}) {
+			t.Errorf("expected AfterGet to be called by Get, got %v", item.calls)
+		}
+		item = &structWithHooks{}
+		err = tx.Each(item, func() error { return nil })
+		if !reflect.DeepEqual(item.calls, []string{"AfterGet"}) {
+			t.Errorf("expected AfterGet to be called by Each, got %v", item.calls)
+		}
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTx_hooks_failure(t *testing.T) {
+	tests := map[string]func(tx *bolster.Tx, item *structWithHooks) error{
+		"BeforeInsert": func(tx *bolster.Tx, item *structWithHooks) error {
+			return tx.Insert(item)
+		},
+		"AfterInsert": func(tx *bolster.Tx, item *structWithHooks) error {
+			return tx.Insert(item)
+		},
+		"BeforeUpdate": func(tx *bolster.Tx, item *structWithHooks) error {
+			item.ID = 1
+			return tx.Update(item)
+		},
+		"BeforeUpsert": func(tx *bolster.Tx, item *structWithHooks) error {
+			return tx.Upsert(item)
+		},
+		"BeforeDelete": func(tx *bolster.Tx, item *structWithHooks) error {
+			item.ID = 1
+			return tx.Delete(item)
+		},
+	}
+	for hook, action := range tests {
+		t.Run(hook, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			err := st.Register(structWithHooks{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithHooks{Name: "foo"})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := internal.DumpStore(st)
+			item := &structWithHooks{Name: "bar", fail: hook}
+			err = st.Write(func(tx *bolster.Tx) error {
+				err := action(tx, item)
+				if err == nil || !strings.Contains(err.Error(), hook+" failed") {
+					t.Errorf("expected error of %s, got %v", hook, err)
+				}
+				// the transaction must not be used after a failed hook
+				err = tx.Insert(&structWithHooks{})
+				if e, ok := err.(bolster.Error); !ok || !e.IsBadTransaction() {
+					t.Errorf("expected ErrBadTransaction, got %v", err)
+				}
+				return nil
+			})
+			if err == nil {
+				t.Error("expected transaction to fail, got nil")
+			}
+			assertSameDump(t, exp, internal.DumpStore(st))
+		})
+	}
+}
+
+func TestTx_hooks_writeOtherItems(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithDenormalisingHook{}, structWithCounter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.Insert(&structWithDenormalisingHook{})
+		tx.Insert(&structWithDenormalisingHook{})
+		return tx.Insert(&structWithDenormalisingHook{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Read(func(tx *bolster.Tx) error {
+		c := &structWithCounter{}
+		err := tx.Get(c, 1)
+		if c.Count != 3 {
+			t.Errorf("expected count 3, got %d", c.Count)
+		}
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,123 @@
+package bolster
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+)
+
+const (
+	tagUUID = "uuid"
+	tagULID = "ulid"
+)
+
+// idGenerator creates IDs for items inserted or upserted with a zero ID.
+type idGenerator int
+
+const (
+	noGenerator idGenerator = iota
+	// uuidGenerator creates random version 4 UUIDs.
+	uuidGenerator
+	// ulidGenerator creates ULIDs: a 48 bit timestamp in milliseconds followed
+	// by 80 random bits. They sort by the time of their creation.
+	ulidGenerator
+)
+
+// crockford is the base32 alphabet of ULIDs. It preserves the sort order of
+// the encoded bytes.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newIDGenerator returns the generator of an ID field tagged `bolster:"uuid"`
+// or `bolster:"ulid"`. The ID must be a string or [16]byte.
+func newIDGenerator(t reflect.Type, stl structTagList, id idField) (idGenerator, error) {
+	uuids, ulids := stl.filter(tagUUID), stl.filter(tagULID)
+	for _, pos := range append(uuids, ulids...) {
+		if pos != id.StructPos {
+			return noGenerator, fmt.Errorf("tags %q and %q are only allowed on the ID field, got field %q", tagUUID, tagULID, t.Field(pos).Name)
+		}
+	}
+	if len(uuids)+len(ulids) == 0 {
+		return noGenerator, nil
+	}
+	if len(uuids)+len(ulids) > 1 {
+		return noGenerator, fmt.Errorf("ID field %q must not have both tags %q and %q", id.Name, tagUUID, tagULID)
+	}
+	if k := id.Type.Kind(); k != reflect.String && (k != reflect.Array || id.Type.Len() != 16 || id.Type.Elem().Kind() != reflect.Uint8) {
+		return noGenerator, fmt.Errorf("generated ID field %q must be a string or [16]byte, got %s", id.Name, id.Type)
+	}
+	if len(uuids) == 1 {
+		return uuidGenerator, nil
+	}
+	return ulidGenerator, nil
+}
+
+// generateID sets the ID field f to a new ID if it is zero.
+func (tx *Tx) generateID(st structType, f reflect.Value) error {
+	if st.ID.Generator == noGenerator || f.Interface() != reflect.Zero(f.Type()).Interface() {
+		return nil
+	}
+	var b [16]byte
+	var s string
+	switch st.ID.Generator {
+	case uuidGenerator:
+		err := tx.store.random(b[:])
+		if err != nil {
+			return err
+		}
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // variant RFC 4122
+		s = formatUUID(b)
+	case ulidGenerator:
+		ms := uint64(tx.store.now().UnixNano() / 1e6)
+		var t [8]byte
+		binary.BigEndian.PutUint64(t[:], ms)
+		copy(b[:6], t[2:])
+		err := tx.store.random(b[6:])
+		if err != nil {
+			return err
+		}
+		s = formatULID(b)
+	}
+	if f.Kind() == reflect.String {
+		tx.setField(f, reflect.ValueOf(s).Convert(f.Type()))
+	} else {
+		tx.setField(f, reflect.ValueOf(b).Convert(f.Type()))
+	}
+	return nil
+}
+
+// formatUUID returns the canonical form of a UUID, e.g.
+// "f47ac10b-58cc-4372-a567-0e02b2c3d479".
+func formatUUID(b [16]byte) string {
+	dst := make([]byte, 36)
+	hex.Encode(dst[0:8], b[0:4])
+	dst[8] = '-'
+	hex.Encode(dst[9:13], b[4:6])
+	dst[13] = '-'
+	hex.Encode(dst[14:18], b[6:8])
+	dst[18] = '-'
+	hex.Encode(dst[19:23], b[8:10])
+	dst[23] = '-'
+	hex.Encode(dst[24:], b[10:])
+	return string(dst)
+}
+
+// formatULID returns the 26 character base32 form of a ULID, e.g.
+// "01ARZ3NDEKTSV4RRFFQ69G5FAV".
+func formatULID(b [16]byte) string {
+	dst := make([]byte, 26)
+	for i := range dst {
+		var v byte
+		for j := 0; j < 5; j++ {
+			// the 128 bits are padded to 130 bits with leading zeros
+			bit := i*5 + j - 2
+			v <<= 1
+			if bit >= 0 && b[bit/8]&(0x80>>uint(bit%8)) != 0 {
+				v |= 1
+			}
+		}
+		dst[i] = crockford[v]
+	}
+	return string(dst)
+}
@@ -0,0 +1,148 @@
+package bolster_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithUUID struct {
+	ID   string `bolster:"id,uuid"`
+	Name string
+}
+
+type structWithUUIDBytes struct {
+	ID [16]byte `bolster:"id,uuid"`
+}
+
+type structWithULID struct {
+	ID   string `bolster:"id,ulid"`
+	Name string
+}
+
+type structWithULIDBytes struct {
+	ID [16]byte `bolster:"id,ulid"`
+}
+
+type structWithUUIDOnInt struct {
+	ID int `bolster:"id,uuid"`
+}
+
+type structWithUUIDOnField struct {
+	ID  int
+	Ref string `bolster:"uuid"`
+}
+
+type structWithUUIDAndULID struct {
+	ID string `bolster:"id,uuid,ulid"`
+}
+
+// countingReader returns consecutive bytes.
+type countingReader struct {
+	next byte
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.next
+		r.next++
+	}
+	return len(p), nil
+}
+
+// TestTx_idgen runs each test on a fresh store with generated IDs and a
+// fixed clock and entropy source.
+func TestTx_idgen(t *testing.T) {
+	tests := map[string]func(t *testing.T, st *bolster.Store, clock *internal.Clock){
+		"InsertUUID": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			items := []*structWithUUID{{}, {ID: "custom"}}
+			bytesItem := &structWithUUIDBytes{}
+			err := st.Write(func(tx *bolster.Tx) error {
+				tx.InsertMany(items)
+				return tx.Upsert(bytesItem)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exp := "00010203-0405-4607-8809-0a0b0c0d0e0f"; items[0].ID != exp {
+				t.Errorf("expected generated UUID %q, got %q", exp, items[0].ID)
+			}
+			if items[1].ID != "custom" {
+				t.Errorf("expected preset ID to be kept, got %q", items[1].ID)
+			}
+			exp := [16]byte{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x46, 0x17, 0x98, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+			if bytesItem.ID != exp {
+				t.Errorf("expected generated UUID % x, got % x", exp, bytesItem.ID)
+			}
+			err = st.Read(func(tx *bolster.Tx) error {
+				return tx.Get(&structWithUUIDBytes{}, exp)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+		"InsertULID": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			first := &structWithULID{Name: "first"}
+			second := &structWithULID{Name: "second"}
+			err := st.Write(func(tx *bolster.Tx) error {
+				err := tx.Insert(first)
+				clock.Add(time.Millisecond)
+				tx.Insert(second)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exp := "01B5BN6S00000G40R40M30E209"; first.ID != exp {
+				t.Errorf("expected generated ULID %q, got %q", exp, first.ID)
+			}
+			if exp := "01B5BN6S01185GR38E1W8124GK"; second.ID != exp {
+				t.Errorf("expected generated ULID %q, got %q", exp, second.ID)
+			}
+			bytesItem := &structWithULIDBytes{}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(bytesItem)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := [16]byte{0x01, 0x59, 0x57, 0x53, 0x64, 0x01, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d}
+			if bytesItem.ID != exp {
+				t.Errorf("expected generated ULID % x, got % x", exp, bytesItem.ID)
+			}
+		},
+		"InsertRollback": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			item := &structWithUUID{}
+			err := st.Write(func(tx *bolster.Tx) error {
+				tx.Savepoint(func(tx *bolster.Tx) error {
+					tx.Insert(item)
+					return errors.New("rollback")
+				})
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.ID != "" {
+				t.Errorf("expected generated ID to be reset on rollback, got %q", item.ID)
+			}
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			clock := internal.SetTestClock(st)
+			st.SetEntropy(&countingReader{})
+			err := st.Register(structWithUUID{}, structWithUUIDBytes{}, structWithULID{}, structWithULIDBytes{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st, clock)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package bolster
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Increment adds delta to an integer field of an existing item and returns
+// the new value of the field. delta may be negative. Use IncrementFloat for
+// float fields.
+// v must be a pointer to a struct of the item's type; the changed item is
+// decoded into v.
+//
+// The item is saved like calling Update with it, e.g. hooks and validation
+// apply and any index of the field is updated. An error is returned if the
+// new value overflows the field or can not be returned as int64. If the item
+// does not exist, ErrNotFound is returned. Composite IDs are passed as a Key.
+func (tx *Tx) Increment(v interface{}, id interface{}, field string, delta int64) (int64, error) {
+	var n int64
+	err := tx.increment(v, id, field, func(f reflect.Value) (err error) {
+		n, err = addDelta(f, delta)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// IncrementFloat is like Increment for float fields. An error is returned if
+// the new value overflows the field or is not a number.
+func (tx *Tx) IncrementFloat(v interface{}, id interface{}, field string, delta float64) (float64, error) {
+	var n float64
+	err := tx.increment(v, id, field, func(f reflect.Value) (err error) {
+		n, err = addFloatDelta(f, delta)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// increment changes field of the item with the given id using add and saves
+// the item.
+func (tx *Tx) increment(v interface{}, id interface{}, field string, add func(reflect.Value) error) error {
+	st, rv, err := tx.validateStruct(v, increment)
+	if tx.errs.HasError() {
+		return tx.addErr(ErrBadTransaction)
+	}
+	if err != nil {
+		return tx.addErr(err)
+	}
+	pos, err := st.mutableField(field)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	item, err := tx.lookup(st, id, increment)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	err = add(item.Field(pos))
+	if err != nil {
+		return tx.addErr(fmt.Errorf("field %q: %w", field, err))
+	}
+	tx.setField(rv, item)
+	err = tx.save(st, rv, v, update)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	return nil
+}
+
+// addDelta adds delta to the integer f and returns the sum.
+func addDelta(f reflect.Value, delta int64) (int64, error) {
+	switch k := f.Kind(); {
+	case k >= reflect.Int && k <= reflect.Int64:
+		old := f.Int()
+		sum := old + delta
+		if delta > 0 && sum < old || delta < 0 && sum > old || f.OverflowInt(sum) {
+			return 0, fmt.Errorf("adding %d to %d overflows %s", delta, old, f.Type())
+		}
+		f.SetInt(sum)
+		return sum, nil
+	case k >= reflect.Uint && k <= reflect.Uint64:
+		old := f.Uint()
+		var sum uint64
+		if delta < 0 {
+			// -(delta+1)+1 avoids overflowing math.MinInt64
+			d := uint64(-(delta + 1)) + 1
+			if d > old {
+				return 0, fmt.Errorf("adding %d to %d overflows %s", delta, old, f.Type())
+			}
+			sum = old - d
+		} else {
+			sum = old + uint64(delta)
+			if sum < old || f.OverflowUint(sum) {
+				return 0, fmt.Errorf("adding %d to %d overflows %s", delta, old, f.Type())
+			}
+		}
+		if sum > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows int64", sum)
+		}
+		f.SetUint(sum)
+		return int64(sum), nil
+	}
+	return 0, fmt.Errorf("expected integer, got %s", f.Type())
+}
+
+// addFloatDelta adds delta to the float f and returns the sum.
+func addFloatDelta(f reflect.Value, delta float64) (float64, error) {
+	if k := f.Kind(); k != reflect.Float32 && k != reflect.Float64 {
+		return 0, fmt.Errorf("expected float, got %s", f.Type())
+	}
+	old := f.Float()
+	sum := old + delta
+	if math.IsNaN(sum) {
+		return 0, fmt.Errorf("adding %v to %v is not a number", delta, old)
+	}
+	if math.IsInf(sum, 0) || f.OverflowFloat(sum) {
+		return 0, fmt.Errorf("adding %v to %v overflows %s", delta, old, f.Type())
+	}
+	f.SetFloat(sum)
+	return f.Float(), nil
+}
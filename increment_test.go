@@ -0,0 +1,168 @@
+package bolster_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithCounters struct {
+	ID     int
+	Views  int64 `bolster:"index"`
+	Stock  uint8
+	Small  int8
+	Big    uint64
+	Score  float64
+	Weight float32
+	Name   string
+}
+
+// TestTx_increment runs each test on a fresh store holding a single item.
+func TestTx_increment(t *testing.T) {
+	tests := map[string]func(t *testing.T, st *bolster.Store){
+		"Increment": func(t *testing.T, st *bolster.Store) {
+			item := &structWithCounters{}
+			err := st.Write(func(tx *bolster.Tx) error {
+				for _, test := range []struct {
+					field string
+					delta int64
+					exp   int64
+				}{
+					{"Views", 1, 11},
+					{"Views", -20, -9},
+					{"Stock", -5, 0},
+					{"Stock", 255, 255},
+					{"Small", -28, -128},
+					{"Big", -1, math.MaxInt64 - 1},
+				} {
+					n, err := tx.Increment(item, 1, test.field, test.delta)
+					if err != nil {
+						return err
+					}
+					if n != test.exp {
+						t.Errorf("%s%+d: expected %d, got %d", test.field, test.delta, test.exp, n)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.Views != -9 || item.Stock != 255 {
+				t.Errorf("expected incremented item, got %+v", item)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+		"IncrementErrors": func(t *testing.T, st *bolster.Store) {
+			exp := internal.DumpStore(st)
+			tests := map[string]struct {
+				id    interface{}
+				field string
+				delta int64
+			}{
+				"missing item":    {2, "Views", 1},
+				"unknown field":   {1, "Foo", 1},
+				"ID":              {1, "ID", 1},
+				"string":          {1, "Name", 1},
+				"int64 overflow":  {1, "Views", math.MaxInt64},
+				"int8 overflow":   {1, "Small", -29},
+				"uint8 overflow":  {1, "Stock", 251},
+				"uint8 underflow": {1, "Stock", -6},
+				"min int64":       {1, "Stock", math.MinInt64},
+				"return value":    {1, "Big", 1},
+				"float":           {1, "Score", 1},
+			}
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					err := st.Write(func(tx *bolster.Tx) error {
+						_, err := tx.Increment(&structWithCounters{}, test.id, test.field, test.delta)
+						return err
+					})
+					t.Log(err)
+					if err == nil {
+						t.Fatal("expected error, got nil")
+					}
+					if e, ok := err.(bolster.Error); name == "missing item" && (!ok || !e.IsNotFound()) {
+						t.Errorf("expected ErrNotFound, got %v", err)
+					}
+					assertSameDump(t, exp, internal.DumpStore(st))
+				})
+			}
+		},
+		"IncrementFloat": func(t *testing.T, st *bolster.Store) {
+			item := &structWithCounters{}
+			err := st.Write(func(tx *bolster.Tx) error {
+				for _, test := range []struct {
+					field string
+					delta float64
+					exp   float64
+				}{
+					{"Score", 1, 2.5},
+					{"Score", -3.25, -0.75},
+					{"Weight", 0.5, 1.5},
+					{"Weight", -math.MaxFloat32, -math.MaxFloat32},
+				} {
+					n, err := tx.IncrementFloat(item, 1, test.field, test.delta)
+					if err != nil {
+						return err
+					}
+					if n != test.exp {
+						t.Errorf("%s%+v: expected %v, got %v", test.field, test.delta, test.exp, n)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.Score != -0.75 || item.Weight != -math.MaxFloat32 {
+				t.Errorf("expected incremented item, got %+v", item)
+			}
+		},
+		"IncrementFloatErrors": func(t *testing.T, st *bolster.Store) {
+			exp := internal.DumpStore(st)
+			tests := map[string]struct {
+				field string
+				delta float64
+			}{
+				"integer":          {"Views", 1},
+				"string":           {"Name", 1},
+				"float64 overflow": {"Score", math.Inf(1)},
+				"float32 overflow": {"Weight", math.MaxFloat64},
+				"NaN":              {"Score", math.NaN()},
+			}
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					err := st.Write(func(tx *bolster.Tx) error {
+						_, err := tx.IncrementFloat(&structWithCounters{}, 1, test.field, test.delta)
+						return err
+					})
+					t.Log(err)
+					if err == nil {
+						t.Fatal("expected error, got nil")
+					}
+					assertSameDump(t, exp, internal.DumpStore(st))
+				})
+			}
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			err := st.Register(structWithCounters{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithCounters{ID: 1, Views: 10, Stock: 5, Small: -100, Big: math.MaxInt64, Score: 1.5, Weight: 1})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/kylelemons/godebug/diff"
@@ -47,7 +48,7 @@ func Gold(t *testing.T, actual []byte, update bool) {
 }
 
 // OpenTestStore returns a fresh store for testing and a function to close and delete it.
-func OpenTestStore(t *testing.T) (*bolster.Store, func()) {
+func OpenTestStore(t testing.TB) (*bolster.Store, func()) {
 	dir, err := ioutil.TempDir("", "bolster_test")
 	if err != nil {
 		t.Fatal(err)
@@ -69,6 +70,29 @@ func OpenTestStore(t *testing.T) (*bolster.Store, func()) {
 	}
 }
 
+// Clock is a clock for testing that only changes when advanced.
+type Clock struct {
+	now time.Time
+}
+
+// SetTestClock sets the clock of st to a Clock starting at January 1, 2017 UTC
+// and returns it.
+func SetTestClock(st *bolster.Store) *Clock {
+	c := &Clock{now: time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	st.SetClock(c.Now)
+	return c
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	return c.now
+}
+
+// Add advances the clock by d.
+func (c *Clock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
 // GoldStore compares the contents of a Store to a golden file named after t.Name()
 func GoldStore(t *testing.T, st *bolster.Store, update bool) {
 	Gold(t, DumpStore(st), update)
@@ -0,0 +1,140 @@
+package bolster
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/nochso/bolster/errlist"
+)
+
+// Patch changes the named fields of an existing item.
+// v must be a pointer to a struct of the item's type; the patched item is
+// decoded into v. fields maps names of exported fields to their new values.
+//
+// Values must be assignable to their field. Numbers are converted to the
+// field's type if it can hold them exactly. nil sets pointers, interfaces,
+// maps and slices to nil. The ID can not be patched.
+//
+// The patched item is saved like calling Update with it, e.g. hooks and
+// validation apply. Only indexes of changed fields are rewritten. If the item
+// does not exist, ErrNotFound is returned. Composite IDs are passed as a Key.
+func (tx *Tx) Patch(v interface{}, id interface{}, fields map[string]interface{}) error {
+	st, rv, err := tx.validateStruct(v, patch)
+	if tx.errs.HasError() {
+		return tx.addErr(ErrBadTransaction)
+	}
+	if err != nil {
+		return tx.addErr(err)
+	}
+	item, err := tx.lookup(st, id, patch)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := errlist.New()
+	for _, name := range names {
+		errs.Append(st.setFieldByName(item, name, fields[name]))
+	}
+	if errs.HasError() {
+		return tx.addErr(errs.ErrorOrNil())
+	}
+	tx.setField(rv, item)
+	return tx.addErr(tx.save(st, rv, v, update))
+}
+
+// lookup returns a copy of the stored item with the given ID. Hidden items are
+// not found.
+func (tx *Tx) lookup(st structType, id interface{}, action txAction) (reflect.Value, error) {
+	values, err := st.idValues(id)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	idBytes, err := st.ID.encode(tx, values, tx.idxBkt(st), action)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	b := tx.dataBkt(st).Get(idBytes)
+	if b == nil {
+		return reflect.Value{}, ErrNotFound
+	}
+	item, err := tx.decode(st, b)
+	if err != nil {
+		return item, err
+	}
+	if st.isHidden(item, tx.store.now) {
+		return reflect.Value{}, ErrNotFound
+	}
+	return item, nil
+}
+
+// setFieldByName sets the exported field name of the struct rv to v.
+func (st structType) setFieldByName(rv reflect.Value, name string, v interface{}) error {
+	pos, err := st.mutableField(name)
+	if err != nil {
+		return err
+	}
+	fv, err := assignableValue(v, st.Type.Field(pos).Type)
+	if err != nil {
+		return fmt.Errorf("field %q: %w", name, err)
+	}
+	rv.Field(pos).Set(fv)
+	return nil
+}
+
+// mutableField returns the position of the exported non-ID field name.
+func (st structType) mutableField(name string) (int, error) {
+	sf, ok := st.Type.FieldByName(name)
+	if !ok || len(sf.Index) != 1 {
+		return -1, fmt.Errorf("unknown field %q", name)
+	}
+	if sf.PkgPath != "" {
+		return -1, fmt.Errorf("field %q must be exported", name)
+	}
+	if st.ID.contains(sf.Index[0]) {
+		return -1, fmt.Errorf("ID field %q can not be changed", name)
+	}
+	return sf.Index[0], nil
+}
+
+// assignableValue returns v as a value assignable to type t.
+// Numbers are converted if t can hold them exactly.
+func assignableValue(v interface{}, t reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, fmt.Errorf("nil can not be assigned to %s", t)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(t) {
+		return rv, nil
+	}
+	if isNumber(rv.Kind()) && isNumber(t.Kind()) {
+		c := rv.Convert(t)
+		if isNegative(c) == isNegative(rv) && c.Convert(rv.Type()).Interface() == v {
+			return c, nil
+		}
+		return reflect.Value{}, fmt.Errorf("%v can not be represented by %s", v, t)
+	}
+	return reflect.Value{}, fmt.Errorf("expected %s, got %s", t, rv.Type())
+}
+
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64 && k != reflect.Uintptr
+}
+
+func isNegative(rv reflect.Value) bool {
+	switch k := rv.Kind(); {
+	case k >= reflect.Int && k <= reflect.Int64:
+		return rv.Int() < 0
+	case k == reflect.Float32 || k == reflect.Float64:
+		return rv.Float() < 0
+	}
+	return false
+}
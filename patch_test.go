@@ -0,0 +1,98 @@
+package bolster_test
+
+import (
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithPatch struct {
+	ID      int
+	Name    string `bolster:"index"`
+	Count   uint16
+	Ratio   float64
+	Tags    []string
+	Version int `bolster:"version"`
+	hidden  int
+}
+
+// TestTx_patch runs each test on a fresh store holding a single item.
+func TestTx_patch(t *testing.T) {
+	tests := map[string]func(t *testing.T, st *bolster.Store){
+		"Patch": func(t *testing.T, st *bolster.Store) {
+			item := &structWithPatch{}
+			err := st.Write(func(tx *bolster.Tx) error {
+				return tx.Patch(item, 1, map[string]interface{}{
+					"Name":  "bar",
+					"Count": 3,
+					"Ratio": 1,
+					"Tags":  nil,
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := structWithPatch{ID: 1, Name: "bar", Count: 3, Ratio: 1, Version: 2}
+			if item.ID != exp.ID || item.Name != exp.Name || item.Count != exp.Count || item.Ratio != exp.Ratio || item.Tags != nil || item.Version != exp.Version {
+				t.Errorf("expected patched item %+v, got %+v", exp, item)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+		"PatchErrors": func(t *testing.T, st *bolster.Store) {
+			exp := internal.DumpStore(st)
+			tests := map[string]struct {
+				id     interface{}
+				fields map[string]interface{}
+			}{
+				"missing item":    {2, map[string]interface{}{"Name": "bar"}},
+				"type of ID":      {"1", map[string]interface{}{"Name": "bar"}},
+				"unknown field":   {1, map[string]interface{}{"Foo": "bar"}},
+				"unexported":      {1, map[string]interface{}{"hidden": 1}},
+				"ID":              {1, map[string]interface{}{"ID": 2}},
+				"type of value":   {1, map[string]interface{}{"Name": 1}},
+				"nil value":       {1, map[string]interface{}{"Count": nil}},
+				"overflow":        {1, map[string]interface{}{"Count": 1 << 16}},
+				"negative":        {1, map[string]interface{}{"Count": -1}},
+				"fraction":        {1, map[string]interface{}{"Count": 1.5}},
+				"version":         {1, map[string]interface{}{"Version": 3}},
+				"multiple fields": {1, map[string]interface{}{"Name": 1, "Count": -1}},
+			}
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					err := st.Write(func(tx *bolster.Tx) error {
+						return tx.Patch(&structWithPatch{}, test.id, test.fields)
+					})
+					t.Log(err)
+					if err == nil {
+						t.Fatal("expected error, got nil")
+					}
+					if e, ok := err.(bolster.Error); name == "missing item" && (!ok || !e.IsNotFound()) {
+						t.Errorf("expected ErrNotFound, got %v", err)
+					}
+					if e, ok := err.(bolster.Error); name == "version" && (!ok || !e.IsVersionConflict()) {
+						t.Errorf("expected ErrVersionConflict, got %v", err)
+					}
+					assertSameDump(t, exp, internal.DumpStore(st))
+				})
+			}
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			err := st.Register(structWithPatch{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithPatch{ID: 1, Name: "foo", Tags: []string{"a"}})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package bolster
+
+import (
+	"bytes"
+
+	"github.com/boltdb/bolt"
+	"github.com/nochso/bolster/errlist"
+)
+
+// Savepoint calls fn and reverts all of its changes if it fails.
+//
+// fn fails when it returns an error or when any action of the transaction
+// fails while fn is running. In that case all changes to items and indexes
+// made within fn are undone, errors of those actions are discarded and the
+// error is returned. The transaction remains usable afterwards.
+//
+// Savepoints can be nested. Changes of a successful savepoint are only
+// reverted when an outer savepoint fails.
+func (tx *Tx) Savepoint(fn func(*Tx) error) error {
+	if tx.errs.HasError() {
+		return tx.addErr(ErrBadTransaction)
+	}
+	outerErrs := tx.errs
+	undoMark, resetMark, changeMark := len(tx.undo), len(tx.resets), len(tx.changes)
+	tx.errs = errlist.New()
+	tx.depth++
+	err := fn(tx)
+	tx.depth--
+	if err == nil {
+		err = tx.errs.ErrorOrNil()
+	}
+	tx.errs = outerErrs
+	if err == nil {
+		if tx.depth == 0 {
+			// nothing left that could be reverted
+			tx.undo = tx.undo[:0]
+			if !tx.batch {
+				tx.resets = tx.resets[:0]
+			}
+		}
+		return nil
+	}
+	tx.rollbackTo(undoMark, resetMark, changeMark)
+	return err
+}
+
+// rollbackTo reverts all writes and changes to items since the given
+// positions of the undo log and list of resets. Changes for watchers are
+// discarded.
+func (tx *Tx) rollbackTo(undoMark, resetMark, changeMark int) {
+	for i := len(tx.undo) - 1; i >= undoMark; i-- {
+		// failing to revert leaves the transaction in an unknown state
+		tx.errs.Append(tx.undo[i]())
+	}
+	tx.undo = tx.undo[:undoMark]
+	for i := len(tx.resets) - 1; i >= resetMark; i-- {
+		tx.resets[i]()
+	}
+	tx.resets = tx.resets[:resetMark]
+	tx.changes = tx.changes[:changeMark]
+}
+
+// logRestore remembers the current value of key when inside a savepoint.
+func (tx *Tx) logRestore(bkt *bolt.Bucket, key []byte) {
+	if tx.depth == 0 {
+		return
+	}
+	key = cloneBytes(key)
+	// bolt.Bucket.Get can not tell apart missing keys and nil values
+	k, v := bkt.Cursor().Seek(key)
+	if !bytes.Equal(k, key) {
+		tx.undo = append(tx.undo, func() error { return bkt.Delete(key) })
+		return
+	}
+	v = cloneBytes(v)
+	tx.undo = append(tx.undo, func() error { return bkt.Put(key, v) })
+}
+
+// logSequence remembers the current sequence of bkt when inside a savepoint.
+func (tx *Tx) logSequence(bkt *bolt.Bucket) {
+	if tx.depth == 0 {
+		return
+	}
+	seq := bkt.Sequence()
+	tx.undo = append(tx.undo, func() error { return bkt.SetSequence(seq) })
+}
+
+// bucketParent is a bolt.Tx or bolt.Bucket holding buckets.
+type bucketParent interface {
+	Bucket(name []byte) *bolt.Bucket
+	CreateBucket(name []byte) (*bolt.Bucket, error)
+	DeleteBucket(name []byte) error
+}
+
+// createBucket returns the bucket name of parent, creating it if needed.
+// Buckets created inside a savepoint are deleted when it fails.
+func (tx *Tx) createBucket(parent bucketParent, name []byte) (*bolt.Bucket, error) {
+	bkt := parent.Bucket(name)
+	if bkt != nil {
+		return bkt, nil
+	}
+	bkt, err := parent.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	if tx.depth > 0 {
+		name = cloneBytes(name)
+		tx.undo = append(tx.undo, func() error { return parent.DeleteBucket(name) })
+	}
+	return bkt, nil
+}
+
+// put sets the value for a key in the bucket.
+// bolt keeps a reference to key and value until the transaction is closed.
+func (tx *Tx) put(bkt *bolt.Bucket, key, value []byte) error {
+	tx.logRestore(bkt, key)
+	return bkt.Put(key, value)
+}
+
+// delete removes a key from the bucket.
+func (tx *Tx) delete(bkt *bolt.Bucket, key []byte) error {
+	tx.logRestore(bkt, key)
+	return bkt.Delete(key)
+}
+
+// nextSequence returns an autoincrementing integer for the bucket.
+func (tx *Tx) nextSequence(bkt *bolt.Bucket) (uint64, error) {
+	tx.logSequence(bkt)
+	return bkt.NextSequence()
+}
+
+// setSequence sets the sequence of the bucket.
+func (tx *Tx) setSequence(bkt *bolt.Bucket, seq uint64) error {
+	tx.logSequence(bkt)
+	return bkt.SetSequence(seq)
+}
+
+// truncate deletes all items and index data of a struct type.
+func (tx *Tx) truncate(st structType) error {
+	if tx.depth == 0 {
+		err := tx.btx.DeleteBucket(st.FullName)
+		if err != nil {
+			return err
+		}
+		return st.init(tx)
+	}
+	// Buckets must not be deleted within a savepoint: the undo log refers to
+	// them. Delete each key instead.
+	return tx.emptyBucket(tx.btx.Bucket(st.FullName))
+}
+
+// emptyBucket deletes all keys of a bucket and its nested buckets.
+// The buckets themselves are kept.
+func (tx *Tx) emptyBucket(bkt *bolt.Bucket) error {
+	var keys, buckets [][]byte
+	c := bkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v == nil && bkt.Bucket(k) != nil {
+			buckets = append(buckets, k)
+		} else {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range buckets {
+		err := tx.emptyBucket(bkt.Bucket(k))
+		if err != nil {
+			return err
+		}
+	}
+	for _, k := range keys {
+		err := tx.delete(bkt, k)
+		if err != nil {
+			return err
+		}
+	}
+	tx.logSequence(bkt)
+	return bkt.SetSequence(0)
+}
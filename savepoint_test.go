@@ -0,0 +1,197 @@
+package bolster_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/kylelemons/godebug/diff"
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithIndexAndAutoincrement struct {
+	ID   int    `bolster:"inc"`
+	Name string `bolster:"index"`
+}
+
+type structWithNonIntegerIDAndIndex struct {
+	Name  string `bolster:"id"`
+	Email string `bolster:"index"`
+}
+
+type structWithNestedIndex struct {
+	ID      int
+	Name    string `bolster:"index NaVi 0"`
+	Visible bool   `bolster:"index NaVi 1"`
+}
+
+func assertSameDump(t *testing.T, exp, act []byte) {
+	if !bytes.Equal(exp, act) {
+		t.Error("-Actual +Expected\n" + diff.Diff(string(act), string(exp)))
+	}
+}
+
+// TestTx_Savepoint runs each test on a fresh store holding the same items.
+func TestTx_Savepoint(t *testing.T) {
+	failure := errors.New("failure")
+	// each function fails within a savepoint and must not leave any trace
+	rollbacks := map[string]func(tx *bolster.Tx) error{
+		"returnedError": func(tx *bolster.Tx) error {
+			tx.Insert(&structWithIndexAndAutoincrement{Name: "baz"})
+			tx.Insert(&structWithNonIntegerIDAndIndex{Name: "baz", Email: "baz@example.com"})
+			return failure
+		},
+		"failedAction": func(tx *bolster.Tx) error {
+			tx.Insert(&structWithIndexAndAutoincrement{Name: "baz"})
+			tx.Update(&structWithIndexAndAutoincrement{ID: 1, Name: "qux"})
+			tx.Upsert(&structWithNonIntegerIDAndIndex{Name: "foo", Email: "qux@example.com"})
+			tx.Delete(&structWithIndexAndAutoincrement{ID: 2})
+			return tx.Insert(&structWithIndexAndAutoincrement{ID: 1}) // duplicate
+		},
+		"truncate": func(tx *bolster.Tx) error {
+			tx.Truncate(structWithIndexAndAutoincrement{})
+			tx.Truncate(structWithNonIntegerIDAndIndex{})
+			tx.Insert(&structWithIndexAndAutoincrement{Name: "baz"})
+			tx.Insert(&structWithNonIntegerIDAndIndex{Name: "baz", Email: "baz@example.com"})
+			return failure
+		},
+		"createdBuckets": func(tx *bolster.Tx) error {
+			item := &structWithHistory{ID: 1, Name: "foo"}
+			tx.Insert(item)
+			item.Name = "bar"
+			tx.Update(item) // creates the history bucket
+			tx.Insert(&structWithNestedIndex{ID: 1, Name: "foo"})
+			_, err := tx.NextSequence("baz")
+			if err != nil {
+				return err
+			}
+			return failure
+		},
+		"nested": func(tx *bolster.Tx) error {
+			err := tx.Savepoint(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithIndexAndAutoincrement{Name: "baz"})
+			})
+			if err != nil {
+				return err
+			}
+			return failure
+		},
+	}
+	tests := make(map[string]func(t *testing.T, st *bolster.Store))
+	for name, fn := range rollbacks {
+		fn := fn
+		tests[name] = func(t *testing.T, st *bolster.Store) {
+			exp := internal.DumpStore(st)
+			err := st.Write(func(tx *bolster.Tx) error {
+				err := tx.Savepoint(fn)
+				if err == nil {
+					t.Error("expected error from savepoint, got nil")
+				} else {
+					t.Log(err)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			assertSameDump(t, exp, internal.DumpStore(st))
+		}
+	}
+	tests["continue"] = func(t *testing.T, st *bolster.Store) {
+		item := &structWithIndexAndAutoincrement{Name: "baz"}
+		err := st.Write(func(tx *bolster.Tx) error {
+			tx.Savepoint(func(tx *bolster.Tx) error {
+				tx.Insert(item)
+				return tx.Insert(&structWithIndexAndAutoincrement{ID: 1})
+			})
+			if item.ID != 0 {
+				t.Errorf("expected autoincremented ID to be reset, got %d", item.ID)
+			}
+			// the transaction is still usable and the ID is not skipped
+			return tx.Insert(item)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if item.ID != 3 {
+			t.Errorf("expected ID 3, got %d", item.ID)
+		}
+	}
+	tests["nestedFailure"] = func(t *testing.T, st *bolster.Store) {
+		err := st.Write(func(tx *bolster.Tx) error {
+			return tx.Savepoint(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithIndexAndAutoincrement{Name: "outer"})
+				err := tx.Savepoint(func(tx *bolster.Tx) error {
+					tx.Insert(&structWithIndexAndAutoincrement{Name: "inner"})
+					return errors.New("failure")
+				})
+				if err == nil {
+					t.Error("expected error from inner savepoint, got nil")
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		var names []string
+		err = st.Read(func(tx *bolster.Tx) error {
+			item := &structWithIndexAndAutoincrement{}
+			return tx.Each(item, func() error {
+				names = append(names, item.Name)
+				return nil
+			})
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(names) != 3 || names[2] != "outer" {
+			t.Errorf("expected only the outer item to be added, got %v", names)
+		}
+	}
+	tests["badTransaction"] = func(t *testing.T, st *bolster.Store) {
+		err := st.Write(func(tx *bolster.Tx) error {
+			tx.Insert(&structWithIndexAndAutoincrement{ID: 1}) // duplicate
+			called := false
+			err := tx.Savepoint(func(tx *bolster.Tx) error {
+				called = true
+				return nil
+			})
+			if called {
+				t.Error("expected savepoint not to be called")
+			}
+			if e, ok := err.(bolster.Error); !ok || !e.IsBadTransaction() {
+				t.Errorf("expected ErrBadTransaction, got %v", err)
+			}
+			return nil
+		})
+		if err == nil {
+			t.Error("expected error, got nil")
+		}
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			err := st.Register(structWithIndexAndAutoincrement{}, structWithNonIntegerIDAndIndex{}, structWithHistory{}, structWithNestedIndex{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.EnableHistory(structWithHistory{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithIndexAndAutoincrement{Name: "foo"})
+				tx.Insert(&structWithIndexAndAutoincrement{Name: "bar"})
+				tx.Insert(&structWithNonIntegerIDAndIndex{Name: "foo", Email: "foo@example.com"})
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st)
+		})
+	}
+}
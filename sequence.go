@@ -0,0 +1,120 @@
+package bolster
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+var bktNameSequences = []byte("sequences")
+
+// sequenceField is an integer field tagged `bolster:"inc"` or
+// `bolster:"inc <name>"` that is set to the next value of a named sequence.
+type sequenceField struct {
+	StructPos int
+	Name      string
+}
+
+// newSequenceFields returns all non-ID fields tagged with "inc".
+//
+// Without a name the sequence is named after the type and field, e.g.
+// "example.com/pkg.Invoice.Number". An ID field tagged "inc <name>" uses the
+// named sequence instead of the type's own.
+func newSequenceFields(t reflect.Type, stl structTagList, id idField) ([]sequenceField, error) {
+	var fields []sequenceField
+	for pos, tags := range stl {
+		for _, tag := range tags {
+			words := strings.Fields(tag)
+			if len(words) == 0 || words[0] != tagAutoIncrement {
+				continue
+			}
+			if len(words) > 2 {
+				return nil, fmt.Errorf("tag %q of field %q must be %q or %q", tag, t.Field(pos).Name, tagAutoIncrement, tagAutoIncrement+" <name>")
+			}
+			if pos == id.StructPos && len(words) == 1 {
+				// autoincremented ID
+				continue
+			}
+			f := t.Field(pos)
+			if k := f.Type.Kind(); k < reflect.Int || k > reflect.Uint64 || k == reflect.Uintptr {
+				return nil, fmt.Errorf("sequence field %q must be an integer, got %s", f.Name, f.Type)
+			}
+			if f.PkgPath != "" {
+				return nil, fmt.Errorf("sequence field %q must be exported", f.Name)
+			}
+			sf := sequenceField{StructPos: pos, Name: t.PkgPath() + "." + t.Name() + "." + f.Name}
+			if len(words) == 2 {
+				sf.Name = words[1]
+			}
+			fields = append(fields, sf)
+		}
+	}
+	return fields, nil
+}
+
+// setSequences sets all zero sequence fields of rv to the next value of their
+// sequence.
+func (tx *Tx) setSequences(st structType, rv reflect.Value) error {
+	for _, sf := range st.Sequences {
+		bkt, err := tx.sequenceBkt(sf.Name)
+		if err != nil {
+			return err
+		}
+		err = tx.autoincrement(rv.Field(sf.StructPos), bkt)
+		if err != nil {
+			return fmt.Errorf("sequence %q: %w", sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// sequenceBkt returns the bucket holding the named sequence, creating it if
+// needed.
+func (tx *Tx) sequenceBkt(name string) (*bolt.Bucket, error) {
+	if name == "" {
+		return nil, errors.New("sequence name must not be empty")
+	}
+	bkt, err := tx.createBucket(tx.btx, bktNameSequences)
+	if err != nil {
+		return nil, err
+	}
+	return tx.createBucket(bkt, []byte(name))
+}
+
+// NextSequence increments the named sequence and returns its new value.
+//
+// Sequences start at zero, i.e. the first call returns 1. Like all writes they
+// are rolled back with the transaction, so committed values have no gaps.
+// Sequences are independent of registered types and not reset by Truncate.
+func (tx *Tx) NextSequence(name string) (uint64, error) {
+	tx.errf = newErrorFactory(sequence)
+	if tx.errs.HasError() {
+		return 0, tx.addErr(ErrBadTransaction)
+	}
+	bkt, err := tx.sequenceBkt(name)
+	if err != nil {
+		return 0, tx.addErr(err)
+	}
+	seq, err := tx.nextSequence(bkt)
+	if err != nil {
+		return 0, tx.addErr(err)
+	}
+	return seq, nil
+}
+
+// SetSequence sets the current value of the named sequence. The next call of
+// NextSequence returns seq+1.
+func (tx *Tx) SetSequence(name string, seq uint64) error {
+	tx.errf = newErrorFactory(sequence)
+	if tx.errs.HasError() {
+		return tx.addErr(ErrBadTransaction)
+	}
+	bkt, err := tx.sequenceBkt(name)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	return tx.addErr(tx.setSequence(bkt, seq))
+}
@@ -0,0 +1,124 @@
+package bolster_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithSequences struct {
+	ID     int    `bolster:"inc"`
+	Number uint16 `bolster:"inc invoices"`
+	Ticket int    `bolster:"inc"`
+}
+
+func nextSequence(t *testing.T, st *bolster.Store, name string) uint64 {
+	var seq uint64
+	err := st.Write(func(tx *bolster.Tx) error {
+		var err error
+		seq, err = tx.NextSequence(name)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seq
+}
+
+func TestTx_NextSequence(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	for i, name := range []string{"a", "a", "b", "a"} {
+		exp := []uint64{1, 2, 1, 3}[i]
+		if seq := nextSequence(t, st, name); seq != exp {
+			t.Errorf("expected sequence %q to be %d, got %d", name, exp, seq)
+		}
+	}
+	errRollback := errors.New("rollback")
+	err := st.Write(func(tx *bolster.Tx) error {
+		tx.NextSequence("a")
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Savepoint(func(tx *bolster.Tx) error {
+			tx.SetSequence("a", 100)
+			return errRollback
+		})
+	})
+	if err != errRollback {
+		t.Fatal(err)
+	}
+	if seq := nextSequence(t, st, "a"); seq != 4 {
+		t.Errorf("expected rolled back sequence to continue with 4, got %d", seq)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.SetSequence("b", 10)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq := nextSequence(t, st, "b"); seq != 11 {
+		t.Errorf("expected sequence to continue with 11, got %d", seq)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		_, err := tx.NextSequence("")
+		return err
+	})
+	if err == nil {
+		t.Error("expected error for empty name, got nil")
+	}
+	err = st.Read(func(tx *bolster.Tx) error {
+		_, err := tx.NextSequence("a")
+		return err
+	})
+	if err == nil {
+		t.Error("expected error within read-only transaction, got nil")
+	}
+	internal.GoldStore(t, st, *updateGold)
+}
+
+func TestTx_Insert_withSequences(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithSequences{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []*structWithSequences{{}, {Number: 100}, {}}
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.InsertMany(items)
+		return tx.SetSequence("invoices", 41)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &structWithSequences{}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Upsert(item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items = append(items, item)
+	exp := []structWithSequences{{1, 1, 1}, {2, 100, 2}, {3, 2, 3}, {4, 42, 4}}
+	for i := range exp {
+		if *items[i] != exp[i] {
+			t.Errorf("expected %+v, got %+v", exp[i], *items[i])
+		}
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		item.Number = 0
+		return tx.Update(item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Number != 0 {
+		t.Errorf("expected Update not to set sequence fields, got %d", item.Number)
+	}
+}
@@ -0,0 +1,162 @@
+package bolster
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const tagDeleted = "deleted"
+
+// newDeletedField returns the position of the time field tagged
+// `bolster:"deleted"` or -1 if there is none. The field is added to indexes.
+func newDeletedField(t reflect.Type, stl structTagList, indexes []index) (int, []index, error) {
+	keys := stl.filter(tagDeleted)
+	if len(keys) > 1 {
+		return -1, indexes, fmt.Errorf("must not have multiple fields with tag %q", tagDeleted)
+	}
+	if len(keys) == 0 {
+		return -1, indexes, nil
+	}
+	f := t.Field(keys[0])
+	if f.Type != timeType {
+		return -1, indexes, fmt.Errorf("deleted field %q must be of type %s, got %s", f.Name, timeType, f.Type)
+	}
+	return keys[0], addIndex(indexes, newTimeIndex(t, keys[0])), nil
+}
+
+// isDeleted returns true if the item rv has been soft deleted.
+func (st structType) isDeleted(rv reflect.Value) bool {
+	if st.Deleted == -1 {
+		return false
+	}
+	return !rv.Field(st.Deleted).Interface().(time.Time).IsZero()
+}
+
+// canHide returns true if items of the type can be hidden from reads.
+func (st structType) canHide() bool {
+	return st.Deleted != -1 || st.Expiry.StructPos != -1
+}
+
+// isHidden returns true if the item rv must not be visible to reads, i.e. it
+// has been soft deleted or has expired.
+func (st structType) isHidden(rv reflect.Value, now func() time.Time) bool {
+	return st.isDeleted(rv) || st.Expiry.isExpired(rv, now)
+}
+
+// markDeleted sets the deleted field of the stored item old to the current
+// time.
+func (tx *Tx) markDeleted(st structType, idBytes []byte, old reflect.Value) error {
+	item := reflect.New(st.Type).Elem()
+	item.Set(old)
+	item.Field(st.Deleted).Set(reflect.ValueOf(tx.store.now()))
+	err := tx.replace(st, idBytes, old, item, ChangeDelete)
+	if err != nil {
+		return err
+	}
+	return tx.recordChange(st, ChangeDelete, old, reflect.Value{})
+}
+
+// replace overwrites the stored item old with item, including index data.
+// ct is the kind of change recorded in the history.
+func (tx *Tx) replace(st structType, idBytes []byte, old, item reflect.Value, ct ChangeType) error {
+	b, err := tx.store.codec.Marshal(item.Interface())
+	if err != nil {
+		return err
+	}
+	err = tx.logHistory(st, idBytes, ct)
+	if err != nil {
+		return err
+	}
+	err = tx.put(tx.dataBkt(st), idBytes, b)
+	if err != nil {
+		return err
+	}
+	return st.updateIndexes(tx, tx.idxBkt(st), old, item, idBytes)
+}
+
+// Restore undoes the soft delete of an item.
+// v must be a pointer to a struct with its ID set. The restored item is
+// decoded into v.
+//
+// An error is returned if the item does not exist or has not been deleted.
+func (tx *Tx) Restore(v interface{}) error {
+	st, rv, err := tx.validateStruct(v, restore)
+	if tx.errs.HasError() {
+		return tx.addErr(ErrBadTransaction)
+	}
+	if err != nil {
+		return tx.addErr(err)
+	}
+	if st.Deleted == -1 {
+		return tx.addErr(fmt.Errorf("type does not support soft delete: missing field tagged %q", tagDeleted))
+	}
+	idBytes, err := st.ID.encodeStruct(tx, rv, tx.idxBkt(st), restore)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	b := tx.dataBkt(st).Get(idBytes)
+	if b == nil {
+		return tx.addErr(ErrNotFound)
+	}
+	old, err := tx.decode(st, b)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	if !st.isDeleted(old) {
+		return tx.addErr(fmt.Errorf("item with ID %q has not been deleted", st.ID.formatStruct(rv)))
+	}
+	item := reflect.New(st.Type).Elem()
+	item.Set(old)
+	item.Field(st.Deleted).Set(reflect.Zero(timeType))
+	err = tx.replace(st, idBytes, old, item, ChangeInsert)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	err = tx.recordChange(st, ChangeInsert, reflect.Value{}, item)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	tx.setField(rv, item)
+	return nil
+}
+
+// Purge removes an item permanently, whether it has been soft deleted or not.
+//
+// Unlike Delete, no hooks are called. If the item does not exist a nil error
+// is returned.
+func (tx *Tx) Purge(v interface{}) error {
+	if tx.errs.HasError() {
+		return tx.addErr(ErrBadTransaction)
+	}
+	st, rv, err := tx.validateStruct(v, purge)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	idBytes, err := st.ID.encodeStruct(tx, rv, tx.idxBkt(st), purge)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return tx.addErr(err)
+	}
+	b := tx.dataBkt(st).Get(idBytes)
+	if b == nil {
+		return nil
+	}
+	old, err := tx.decode(st, b)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	return tx.addErr(tx.purge(st, idBytes, old))
+}
+
+// PurgeDeleted permanently removes items of all registered types that have
+// been soft deleted at least olderThan ago.
+//
+// Items are removed in batches of at most ExpireBatchSize items per
+// transaction. The amount of removed items is returned.
+func (s *Store) PurgeDeleted(olderThan time.Duration) (int, error) {
+	return s.purgeTimeIndexes(s.now().Add(-olderThan), false, func(st structType) int {
+		return st.Deleted
+	})
+}
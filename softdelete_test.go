@@ -0,0 +1,184 @@
+package bolster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithSoftDelete struct {
+	ID        int       `bolster:"inc"`
+	Name      string    `bolster:"index"`
+	DeletedAt time.Time `bolster:"deleted"`
+	calls     int
+}
+
+func (s *structWithSoftDelete) BeforeDelete(tx *bolster.Tx) error {
+	s.calls++
+	return nil
+}
+
+// TestTx_softDelete runs each test on a fresh store holding two items.
+func TestTx_softDelete(t *testing.T) {
+	tests := map[string]func(t *testing.T, st *bolster.Store, clock *internal.Clock){
+		"Delete": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			item := &structWithSoftDelete{ID: 1}
+			err := st.Write(func(tx *bolster.Tx) error {
+				tx.Delete(item)
+				return tx.Delete(item) // already deleted
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.calls != 1 {
+				t.Errorf("expected BeforeDelete to be called once, got %d", item.calls)
+			}
+			internal.GoldStore(t, st, *updateGold)
+			err = st.Read(func(tx *bolster.Tx) error {
+				err := tx.Get(&structWithSoftDelete{}, 1)
+				if e, ok := err.(bolster.Error); !ok || !e.IsNotFound() {
+					t.Errorf("expected ErrNotFound for deleted item, got %v", err)
+				}
+				var names []string
+				item := &structWithSoftDelete{}
+				err = tx.Each(item, func() error {
+					names = append(names, item.Name)
+					return nil
+				})
+				if len(names) != 1 || names[0] != "bar" {
+					t.Errorf("expected Each to skip deleted items, got %v", names)
+				}
+				return err
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			for name, action := range map[string]func(*bolster.Tx, interface{}) error{
+				"insert": (*bolster.Tx).Insert,
+				"update": (*bolster.Tx).Update,
+				"upsert": (*bolster.Tx).Upsert,
+			} {
+				err = st.Write(func(tx *bolster.Tx) error {
+					return action(tx, &structWithSoftDelete{ID: 1, Name: "baz"})
+				})
+				if err == nil {
+					t.Errorf("%s: expected error when overwriting a deleted item, got nil", name)
+				} else {
+					t.Log(err)
+				}
+			}
+		},
+		"EachDeletedLast": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			err := st.Write(func(tx *bolster.Tx) error {
+				return tx.Delete(&structWithSoftDelete{ID: 2})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			item := &structWithSoftDelete{}
+			err = st.Read(func(tx *bolster.Tx) error {
+				return tx.Each(item, func() error { return nil })
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			if item.ID != 1 || item.Name != "foo" {
+				t.Errorf("expected last visible item after Each, got %+v", item)
+			}
+		},
+		"Restore": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			exp := internal.DumpStore(st)
+			err := st.Write(func(tx *bolster.Tx) error {
+				return tx.Delete(&structWithSoftDelete{ID: 1})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			item := &structWithSoftDelete{ID: 1}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Restore(item)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.Name != "foo" || !item.DeletedAt.IsZero() {
+				t.Errorf("expected restored item to be decoded, got %+v", item)
+			}
+			assertSameDump(t, exp, internal.DumpStore(st))
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Restore(&structWithSoftDelete{ID: 2})
+			})
+			if err == nil {
+				t.Error("expected error when restoring an item that is not deleted, got nil")
+			}
+		},
+		"Purge": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			err := st.Write(func(tx *bolster.Tx) error {
+				tx.Delete(&structWithSoftDelete{ID: 1})
+				tx.Purge(&structWithSoftDelete{ID: 1})
+				tx.Purge(&structWithSoftDelete{ID: 2})
+				return tx.Purge(&structWithSoftDelete{ID: 3}) // does not exist
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+		"PurgeDeleted": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			err := st.Write(func(tx *bolster.Tx) error {
+				return tx.Delete(&structWithSoftDelete{ID: 1})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock.Add(time.Hour)
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Delete(&structWithSoftDelete{ID: 2})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := st.PurgeDeleted(time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 1 {
+				t.Errorf("expected 1 purged item, got %d", n)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				tx.Restore(&structWithSoftDelete{ID: 1})
+				return nil
+			})
+			if err == nil {
+				t.Error("expected purged item to be gone, got nil")
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Restore(&structWithSoftDelete{ID: 2})
+			})
+			if err != nil {
+				t.Errorf("expected recently deleted item to be kept, got %v", err)
+			}
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			clock := internal.SetTestClock(st)
+			err := st.Register(structWithSoftDelete{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithSoftDelete{Name: "foo"})
+				return tx.Insert(&structWithSoftDelete{Name: "bar"})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st, clock)
+		})
+	}
+}
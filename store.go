@@ -1,10 +1,15 @@
 package bolster
 
 import (
+	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"sync"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/nochso/bolster/codec"
@@ -22,6 +27,13 @@ type Store struct {
 	codec codec.Interface
 	db    *bolt.DB
 	types map[reflect.Type]structType
+	clock func() time.Time
+	// entropy is the source of randomness for generated IDs
+	entropy io.Reader
+
+	watchMu  sync.Mutex
+	watchers []*watcher
+	closed   bool
 }
 
 // Open creates and opens a Store.
@@ -38,6 +50,43 @@ func Open(path string, mode os.FileMode, options *bolt.Options) (*Store, error)
 	return st, nil
 }
 
+// SetClock sets the function returning the current time, e.g. for filling
+// fields tagged `bolster:"created"` or `bolster:"updated"`.
+//
+// It defaults to time.Now. Passing nil restores the default. SetClock must not
+// be called concurrently with transactions.
+func (s *Store) SetClock(clock func() time.Time) {
+	s.clock = clock
+}
+
+// SetEntropy sets the source of randomness for generating IDs of fields
+// tagged `bolster:"uuid"` or `bolster:"ulid"`, e.g. for deterministic tests.
+//
+// It defaults to crypto/rand.Reader. Passing nil restores the default.
+// SetEntropy must not be called concurrently with transactions.
+func (s *Store) SetEntropy(r io.Reader) {
+	s.entropy = r
+}
+
+// random fills b using the store's source of randomness.
+func (s *Store) random(b []byte) error {
+	r := s.entropy
+	if r == nil {
+		r = rand.Reader
+	}
+	_, err := io.ReadFull(r, b)
+	return err
+}
+
+// now returns the current time of the store's clock.
+// The monotonic clock reading is stripped as it can not be stored.
+func (s *Store) now() time.Time {
+	if s.clock == nil {
+		return time.Now().Round(0)
+	}
+	return s.clock().Round(0)
+}
+
 // Bolt returns the underlying bolt.DB instance.
 func (s *Store) Bolt() *bolt.DB {
 	return s.db
@@ -45,15 +94,26 @@ func (s *Store) Bolt() *bolt.DB {
 
 // Close releases all database resources.
 // All transactions must be closed before closing the database.
+// The channels of all watchers are closed.
 func (s *Store) Close() error {
+	s.closeWatchers()
 	return s.db.Close()
 }
 
 // Read executes a function within the context of a managed read-only transaction.
 // Any error that is returned from the function is returned from the Read() method.
 func (s *Store) Read(fn func(*Tx) error) error {
+	return s.ReadContext(context.Background(), fn)
+}
+
+// ReadContext is like Read. Actions of the transaction fail once ctx is done.
+func (s *Store) ReadContext(ctx context.Context, fn func(*Tx) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return newErrorFactory(get).with(err)
+	}
 	return s.db.View(func(btx *bolt.Tx) error {
-		tx := &Tx{btx: btx, store: s, errs: errlist.New()}
+		tx := newTx(ctx, s, btx, true)
 		err := fn(tx)
 		if err != nil {
 			return err
@@ -68,22 +128,93 @@ func (s *Store) Read(fn func(*Tx) error) error {
 // Any error that is returned from the function or returned from the commit is
 // returned from the Write() method.
 func (s *Store) Write(fn func(*Tx) error) error {
+	return s.WriteContext(context.Background(), fn)
+}
+
+// WriteContext is like Write. Actions of the transaction fail once ctx is
+// done and the transaction is rolled back.
+//
+// The returned error wraps the context's error, which can be checked using
+// errors.Is(err, context.Canceled).
+func (s *Store) WriteContext(ctx context.Context, fn func(*Tx) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return newErrorFactory(commit).with(err)
+	}
 	return s.db.Update(func(btx *bolt.Tx) error {
-		tx := &Tx{btx: btx, store: s, errs: errlist.New()}
+		tx := newTx(ctx, s, btx, true)
 		err := fn(tx)
 		if err != nil {
 			return err
 		}
+		err = tx.checkContext()
+		if err != nil {
+			// returned on its own so that errors.Is does not need to
+			// unwrap a list of errors
+			return err
+		}
 		return tx.errs.ErrorOrNil()
 	})
 }
 
+// Batch calls fn as part of a batch of read-write transactions.
+//
+// It behaves like Write, however concurrent calls to Batch are combined into a
+// single transaction. This can improve throughput when many goroutines write
+// small amounts of data. See bolt.DB.Batch for details.
+//
+// fn may be called multiple times: if any function of a batch fails, the
+// whole batch is rolled back and retried. fn must be idempotent and must not
+// depend on side effects outside of the transaction. Changes bolster makes to
+// the items passed to Tx, e.g. autoincremented IDs, are reverted before a
+// retry.
+func (s *Store) Batch(fn func(*Tx) error) error {
+	var resets []func()
+	return s.db.Batch(func(btx *bolt.Tx) error {
+		// fn was called before and that transaction has been rolled back
+		for i := len(resets) - 1; i >= 0; i-- {
+			resets[i]()
+		}
+		tx := newTx(context.Background(), s, btx, true)
+		tx.batch = true
+		err := fn(tx)
+		resets = tx.resets
+		if err != nil {
+			return err
+		}
+		return tx.errs.ErrorOrNil()
+	})
+}
+
+// Begin starts a new transaction that must be closed by calling Commit or
+// Rollback.
+//
+// Only one read-write transaction is allowed at a time. Starting a read-write
+// transaction while another one is open blocks until it is closed.
+// Read-only transactions must be rolled back, they can not be committed.
+func (s *Store) Begin(writable bool) (*Tx, error) {
+	return s.BeginContext(context.Background(), writable)
+}
+
+// BeginContext is like Begin. Actions of the transaction fail once ctx is
+// done and Commit rolls back instead.
+func (s *Store) BeginContext(ctx context.Context, writable bool) (*Tx, error) {
+	btx, err := s.db.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return newTx(ctx, s, btx, false), nil
+}
+
 // Register validates struct types for later use.
 // This will require a write-transaction if the the struct type has never been
 // registered in this store or its definition has been changed since the last
 // call.
 // A struct's type must be registered before it can be used in combination with
 // a Store.
+//
+// Indexes that are new to the store are built from any existing items.
+// Indexes that are no longer part of the struct definition are deleted.
 func (s *Store) Register(v ...interface{}) error {
 	errs := errlist.New()
 	for _, vv := range v {
@@ -1,8 +1,17 @@
 package bolster_test
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/nochso/bolster"
 	"github.com/nochso/bolster/internal"
 )
 
@@ -48,6 +57,26 @@ type structWithMultiFieldIndexAndWrongOrder struct {
 	Visible bool   `bolster:"index NaVi 2"`
 }
 
+type structWithCollationOnInt struct {
+	ID    int
+	Count int `bolster:"index,fold"`
+}
+
+type structWithInvalidRule struct {
+	ID   int
+	Name string `bolster:"min three"`
+}
+
+type structWithTimestampOnString struct {
+	ID        int
+	CreatedAt string `bolster:"created"`
+}
+
+type structWithSequenceOnString struct {
+	ID     int
+	Number string `bolster:"inc"`
+}
+
 func TestStore_Register(t *testing.T) {
 	st, closer := internal.OpenTestStore(t)
 	defer closer()
@@ -121,6 +150,94 @@ func TestStore_Register(t *testing.T) {
 			t.Log(err)
 		}
 	})
+	t.Run("structWithCollationOnInt", func(t *testing.T) {
+		err := st.Register(structWithCollationOnInt{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithInvalidRule", func(t *testing.T) {
+		err := st.Register(structWithInvalidRule{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithTimestampOnString", func(t *testing.T) {
+		err := st.Register(structWithTimestampOnString{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithTTLOnString", func(t *testing.T) {
+		err := st.Register(structWithTTLOnString{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithSequenceOnString", func(t *testing.T) {
+		err := st.Register(structWithSequenceOnString{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithUUIDOnInt", func(t *testing.T) {
+		err := st.Register(structWithUUIDOnInt{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithUUIDOnField", func(t *testing.T) {
+		err := st.Register(structWithUUIDOnField{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithUUIDAndULID", func(t *testing.T) {
+		err := st.Register(structWithUUIDAndULID{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithIncompleteCompositeID", func(t *testing.T) {
+		err := st.Register(structWithIncompleteCompositeID{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithMixedCompositeID", func(t *testing.T) {
+		err := st.Register(structWithMixedCompositeID{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
+	t.Run("structWithAutoincrementedCompositeID", func(t *testing.T) {
+		err := st.Register(structWithAutoincrementedCompositeID{})
+		if err == nil {
+			t.Errorf("expected error, got %v", err)
+		} else {
+			t.Log(err)
+		}
+	})
 	t.Run("structWithSingleFieldIndex", func(t *testing.T) {
 		st, closer := internal.OpenTestStore(t)
 		defer closer()
@@ -152,3 +269,225 @@ func TestStore_Register(t *testing.T) {
 		internal.GoldStore(t, st, *updateGold)
 	})
 }
+
+func TestStore_Register_migrateIndexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bolster_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bolster.db")
+	// Each step declares its own version of type "item" with the same full
+	// name, simulating a changed struct definition.
+	open := func(t *testing.T, v interface{}) *bolster.Store {
+		st, err := bolster.Open(path, 0644, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = st.Register(v)
+		if err != nil {
+			t.Error(err)
+		}
+		return st
+	}
+	created := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	t.Run("withoutIndex", func(t *testing.T) {
+		type item struct {
+			ID      int
+			Created time.Time
+			Score   float64
+		}
+		st := open(t, item{})
+		defer st.Close()
+		err := st.Write(func(tx *bolster.Tx) error {
+			tx.Insert(&item{ID: 1, Created: created, Score: math.Copysign(0, -1)})
+			tx.Insert(&item{ID: 2, Created: created.AddDate(-1, 0, 0)})
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("added", func(t *testing.T) {
+		type item struct {
+			ID      int
+			Created time.Time `bolster:"index"`
+			Score   float64   `bolster:"index"`
+		}
+		st := open(t, item{})
+		defer st.Close()
+		internal.GoldStore(t, st, *updateGold)
+	})
+	t.Run("removed", func(t *testing.T) {
+		type item struct {
+			ID      int
+			Created time.Time
+			Score   float64
+		}
+		st := open(t, item{})
+		defer st.Close()
+		internal.GoldStore(t, st, *updateGold)
+	})
+}
+
+func TestStore_Begin(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithID{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists := func(t *testing.T, id int) bool {
+		err := st.Read(func(tx *bolster.Tx) error {
+			return tx.Get(&structWithID{}, id)
+		})
+		if e, ok := err.(bolster.Error); ok && e.IsNotFound() {
+			return false
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return true
+	}
+	t.Run("Commit", func(t *testing.T) {
+		tx, err := st.Begin(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tx.Insert(&structWithID{1})
+		if err != nil {
+			t.Error(err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			t.Error(err)
+		}
+		if !exists(t, 1) {
+			t.Error("expected committed item to exist")
+		}
+	})
+	t.Run("Rollback", func(t *testing.T) {
+		tx, err := st.Begin(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tx.Insert(&structWithID{2})
+		if err != nil {
+			t.Error(err)
+		}
+		err = tx.Rollback()
+		if err != nil {
+			t.Error(err)
+		}
+		if exists(t, 2) {
+			t.Error("expected rolled back item to be missing")
+		}
+	})
+	t.Run("CommitWithErrors", func(t *testing.T) {
+		tx, err := st.Begin(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.Insert(&structWithID{3})
+		tx.Insert(&structWithID{1}) // duplicate
+		err = tx.Commit()
+		if err == nil {
+			t.Error("expected error, got nil")
+		} else {
+			t.Log(err)
+		}
+		if exists(t, 3) {
+			t.Error("expected item of failed transaction to be missing")
+		}
+	})
+	t.Run("readOnly", func(t *testing.T) {
+		tx, err := st.Begin(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		act := &structWithID{}
+		err = tx.Get(act, 1)
+		if err != nil {
+			t.Error(err)
+		}
+		err = tx.Commit()
+		if err == nil {
+			t.Error("expected error when committing read-only transaction, got nil")
+		}
+		err = tx.Rollback()
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("managed", func(t *testing.T) {
+		err := st.Write(func(tx *bolster.Tx) error {
+			if err := tx.Commit(); err != bolster.ErrManagedTx {
+				t.Errorf("expected ErrManagedTx, got %v", err)
+			}
+			if err := tx.Rollback(); err != bolster.ErrManagedTx {
+				t.Errorf("expected ErrManagedTx, got %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestStore_Batch(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIncrementingIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make sure all functions end up in the same batch.
+	const n = 10
+	st.Bolt().MaxBatchSize = n
+	st.Bolt().MaxBatchDelay = time.Second
+	items := make([]*structWithIncrementingIDAndField, n)
+	errs := make([]error, n)
+	calls := make([]int, n)
+	total := 0
+	wg := &sync.WaitGroup{}
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			items[i] = &structWithIncrementingIDAndField{Name: fmt.Sprint(i)}
+			errs[i] = st.Batch(func(tx *bolster.Tx) error {
+				// functions of a batch are called one after another
+				calls[i]++
+				total++
+				err := tx.Insert(items[i])
+				if total == n {
+					// fail the last function of the first attempt, forcing
+					// a retry of all others
+					return errors.New("failure")
+				}
+				return err
+			})
+		}(i)
+	}
+	wg.Wait()
+	ids := make(map[int]bool)
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Errorf("#%d: %s", i, errs[i])
+		}
+		if calls[i] != 2 {
+			t.Errorf("#%d: expected function to be called twice, got %d call(s)", i, calls[i])
+		}
+		if ids[items[i].ID] {
+			t.Errorf("#%d: duplicate ID %d", i, items[i].ID)
+		}
+		ids[items[i].ID] = true
+	}
+	// autoincremented IDs of the failed attempt must not be reused
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(&structWithIncrementingIDAndField{})
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
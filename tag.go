@@ -3,6 +3,8 @@ package bolster
 import (
 	"reflect"
 	"strings"
+
+	"github.com/nochso/bolster/bytesort"
 )
 
 const (
@@ -44,3 +46,17 @@ func (stl structTagList) contains(i int, s string) bool {
 	}
 	return false
 }
+
+// collation returns the combined collation of the i'th field's tags.
+//
+// Collation tags are named after the flags of bytesort.Collation, e.g.
+// `bolster:"index,fold"` for a case-insensitive index.
+func (stl structTagList) collation(i int) bytesort.Collation {
+	var c bytesort.Collation
+	for _, w := range stl[i] {
+		if flag, err := bytesort.ParseCollation(w); err == nil {
+			c |= flag
+		}
+	}
+	return c
+}
@@ -0,0 +1,80 @@
+package bolster
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	tagCreated = "created"
+	tagUpdated = "updated"
+)
+
+// timestampFields holds the positions of fields tagged `bolster:"created"` and
+// `bolster:"updated"`. Positions are -1 when a struct has no such field.
+type timestampFields struct {
+	Created int
+	Updated int
+}
+
+func newTimestampFields(t reflect.Type, stl structTagList) (timestampFields, error) {
+	ts := timestampFields{Created: -1, Updated: -1}
+	for _, tag := range []string{tagCreated, tagUpdated} {
+		keys := stl.filter(tag)
+		if len(keys) > 1 {
+			return ts, fmt.Errorf("must not have multiple fields with tag %q", tag)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if f := t.Field(keys[0]); f.Type != timeType {
+			return ts, fmt.Errorf("field %q with tag %q must be of type %s, got %s", f.Name, tag, timeType, f.Type)
+		}
+		if tag == tagCreated {
+			ts.Created = keys[0]
+		} else {
+			ts.Updated = keys[0]
+		}
+	}
+	return ts, nil
+}
+
+// setTimestamps fills the created and updated fields of rv.
+//
+// old is the stored item or an invalid value if the item is new. The created
+// time of existing items is kept. New items keep a created time that is
+// already set.
+func (tx *Tx) setTimestamps(st structType, rv, old reflect.Value) {
+	if st.Timestamps.Created == -1 && st.Timestamps.Updated == -1 {
+		return
+	}
+	now := reflect.ValueOf(tx.store.now())
+	if pos := st.Timestamps.Created; pos != -1 {
+		if old.IsValid() {
+			tx.setField(rv.Field(pos), old.Field(pos))
+		} else if rv.Field(pos).Interface().(time.Time).IsZero() {
+			tx.setField(rv.Field(pos), now)
+		}
+	}
+	if pos := st.Timestamps.Updated; pos != -1 {
+		tx.setField(rv.Field(pos), now)
+	}
+}
+
+// setField sets the value of a field and remembers how to revert it.
+func (tx *Tx) setField(f, v reflect.Value) {
+	prev := reflect.New(f.Type()).Elem()
+	prev.Set(f)
+	f.Set(v)
+	tx.addReset(func() { f.Set(prev) })
+}
+
+// addReset remembers how to revert a change made to an item. Resets are only
+// needed to retry a batch or to roll back a savepoint, so other transactions
+// do not keep them.
+func (tx *Tx) addReset(fn func()) {
+	if tx.batch || tx.depth > 0 {
+		tx.resets = append(tx.resets, fn)
+	}
+}
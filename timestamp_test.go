@@ -0,0 +1,90 @@
+package bolster_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithTimestamps struct {
+	ID        int       `bolster:"inc"`
+	Name      string    `bolster:"index"`
+	CreatedAt time.Time `bolster:"created"`
+	UpdatedAt time.Time `bolster:"updated,index"`
+}
+
+func TestTx_timestamps(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	clock := internal.SetTestClock(st)
+	err := st.Register(structWithTimestamps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := func(n int) time.Time {
+		return time.Date(2017, 1, 1, 0, 0, n, 0, time.UTC)
+	}
+	item := &structWithTimestamps{Name: "foo"}
+	clock.Add(time.Second)
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !item.CreatedAt.Equal(second(1)) || !item.UpdatedAt.Equal(second(1)) {
+		t.Errorf("expected both timestamps to be set on insert, got %v and %v", item.CreatedAt, item.UpdatedAt)
+	}
+
+	// a created time passed to Update is ignored
+	update := &structWithTimestamps{ID: item.ID, Name: "bar"}
+	clock.Add(time.Second)
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Update(update)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !update.CreatedAt.Equal(second(1)) || !update.UpdatedAt.Equal(second(2)) {
+		t.Errorf("expected created time to be kept on update, got %v and %v", update.CreatedAt, update.UpdatedAt)
+	}
+
+	upsert := &structWithTimestamps{ID: item.ID, Name: "baz"}
+	clock.Add(time.Second)
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Upsert(upsert)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !upsert.CreatedAt.Equal(second(1)) || !upsert.UpdatedAt.Equal(second(3)) {
+		t.Errorf("expected created time to be kept on upsert, got %v and %v", upsert.CreatedAt, upsert.UpdatedAt)
+	}
+
+	internal.GoldStore(t, st, *updateGold)
+}
+
+func TestTx_timestamps_reset(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	internal.SetTestClock(st)
+	err := st.Register(structWithTimestamps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &structWithTimestamps{Name: "foo"}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Savepoint(func(tx *bolster.Tx) error {
+			tx.Insert(item)
+			return tx.Insert(&structWithTimestamps{ID: item.ID}) // duplicate
+		})
+	})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !item.CreatedAt.IsZero() || !item.UpdatedAt.IsZero() {
+		t.Errorf("expected timestamps to be reset, got %v and %v", item.CreatedAt, item.UpdatedAt)
+	}
+}
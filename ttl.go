@@ -0,0 +1,196 @@
+package bolster
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/nochso/bolster/bytesort"
+)
+
+const (
+	tagExpires = "expires"
+	tagTTL     = "ttl"
+)
+
+// ExpireBatchSize is the maximum amount of expired items deleted within a
+// single transaction by DeleteExpired.
+const ExpireBatchSize = 256
+
+// expiryField is a time field tagged `bolster:"expires"` or `bolster:"ttl"`.
+// Items expire once the current time reaches the field's value. Zero times
+// never expire.
+type expiryField struct {
+	StructPos int // -1 if the struct has no such field
+}
+
+func newExpiryField(t reflect.Type, stl structTagList, indexes []index) (expiryField, []index, error) {
+	ef := expiryField{StructPos: -1}
+	keys := append(stl.filter(tagExpires), stl.filter(tagTTL)...)
+	if len(keys) > 1 {
+		return ef, indexes, fmt.Errorf("must not have multiple fields with tag %q or %q", tagExpires, tagTTL)
+	}
+	if len(keys) == 0 {
+		return ef, indexes, nil
+	}
+	ef.StructPos = keys[0]
+	f := t.Field(ef.StructPos)
+	if f.Type != timeType {
+		return ef, indexes, fmt.Errorf("expiry field %q must be of type %s, got %s", f.Name, timeType, f.Type)
+	}
+	return ef, addIndex(indexes, newTimeIndex(t, ef.StructPos)), nil
+}
+
+// isExpired returns true if the item rv has expired at the current time.
+// now is only called for items that can expire.
+func (ef expiryField) isExpired(rv reflect.Value, now func() time.Time) bool {
+	if ef.StructPos == -1 {
+		return false
+	}
+	t := rv.Field(ef.StructPos).Interface().(time.Time)
+	return !t.IsZero() && !t.After(now())
+}
+
+// DeleteExpired deletes all expired items of all registered types.
+//
+// Items are deleted in batches of at most ExpireBatchSize items per
+// transaction. Deleting an item is like calling Tx.Delete, i.e. hooks,
+// watchers and the changelog are notified. Items are deleted even if their
+// type supports soft delete. The amount of deleted items is returned.
+func (s *Store) DeleteExpired() (int, error) {
+	return s.purgeTimeIndexes(s.now(), true, func(st structType) int {
+		return st.Expiry.StructPos
+	})
+}
+
+// purgeTimeIndexes deletes items of all types whose time field sorts before or
+// at until. Zero times are ignored. field returns the position of the time
+// field of a type or -1. The field must be part of an index.
+func (s *Store) purgeTimeIndexes(until time.Time, hooks bool, field func(structType) int) (int, error) {
+	total := 0
+	for _, st := range s.types {
+		pos := field(st)
+		if pos == -1 {
+			continue
+		}
+		for {
+			n := 0
+			err := s.Write(func(tx *Tx) error {
+				var err error
+				n, err = tx.purgeTimeIndex(st, pos, until, hooks, ExpireBatchSize)
+				return err
+			})
+			if err != nil {
+				return total, err
+			}
+			total += n
+			if n < ExpireBatchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// StartExpirer calls DeleteExpired in the background every interval.
+//
+// Calling stop ends the expirer and returns the last error of
+// DeleteExpired, if any. The store must not be closed before calling stop.
+func (s *Store) StartExpirer(interval time.Duration) (stop func() error) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var lastErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, err := s.DeleteExpired()
+				if err != nil {
+					lastErr = err
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() error {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+		return lastErr
+	}
+}
+
+// purgeTimeIndex deletes up to limit items whose time field at pos sorts
+// before or at until. If hooks is true, the delete hooks of the items are
+// called.
+func (tx *Tx) purgeTimeIndex(st structType, pos int, until time.Time, hooks bool, limit int) (int, error) {
+	tx.errf = newErrorFactory(delete, st)
+	idx := newTimeIndex(st.Type, pos)
+	bkt := tx.idxBkt(st).Bucket(idx.FullName)
+	end := bytesort.AppendTime(nil, until)
+	// zero times are ignored and sort before any other time
+	start := bytesort.AppendTime(nil, time.Time{}.Add(time.Nanosecond))
+	var keys [][]byte
+	c := bkt.Cursor()
+	for k, _ := c.Seek(start); k != nil && len(keys) < limit; k, _ = c.Next() {
+		if bytes.Compare(k[:len(k)-8], end) > 0 {
+			break
+		}
+		keys = append(keys, cloneBytes(k))
+	}
+	bktData := tx.dataBkt(st)
+	for _, k := range keys {
+		// index keys end with the 8 byte ID of the item
+		id := k[len(k)-8:]
+		b := bktData.Get(id)
+		if b != nil {
+			old, err := tx.decode(st, b)
+			if err != nil {
+				return 0, tx.addErr(err)
+			}
+			if hooks {
+				err = tx.removeID(st, id, old.Addr().Interface(), false)
+			} else {
+				err = tx.purge(st, id, old)
+			}
+			if err != nil {
+				return 0, tx.addErr(err)
+			}
+		}
+		if bkt.Get(k) != nil {
+			// the index is out of sync, remove the entry to make progress
+			err := tx.delete(bkt, k)
+			if err != nil {
+				return 0, tx.addErr(err)
+			}
+		}
+	}
+	return len(keys), nil
+}
+
+// newTimeIndex returns an index of the time field at pos.
+func newTimeIndex(t reflect.Type, pos int) index {
+	idx := index{Fields: []indexField{{StructPos: pos, StructField: t.Field(pos)}}}
+	idx.FullName = idx.getFullName()
+	return idx
+}
+
+// addIndex appends idx to indexes unless an index of the same name exists,
+// e.g. when a field is already indexed explicitly.
+func addIndex(indexes []index, idx index) []index {
+	for _, i := range indexes {
+		if bytes.Equal(i.FullName, idx.FullName) {
+			return indexes
+		}
+	}
+	return append(indexes, idx)
+}
@@ -0,0 +1,228 @@
+package bolster_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithExpiry struct {
+	ID      int       `bolster:"inc"`
+	Token   string    `bolster:"index"`
+	Expires time.Time `bolster:"expires"`
+}
+
+type structWithTTLOnString struct {
+	ID      int
+	Expires string `bolster:"ttl"`
+}
+
+type structWithStringIDAndExpiry struct {
+	Key     string    `bolster:"id"`
+	Expires time.Time `bolster:"ttl"`
+}
+
+// TestTx_expiry runs each test on a fresh store with a test clock.
+func TestTx_expiry(t *testing.T) {
+	tests := map[string]func(t *testing.T, st *bolster.Store, clock *internal.Clock){
+		"Get": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			err := st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithExpiry{Token: "never"})
+				tx.Insert(&structWithExpiry{Token: "soon", Expires: clock.Now().Add(10 * time.Second)})
+				return tx.Insert(&structWithExpiry{Token: "later", Expires: clock.Now().Add(20 * time.Second)})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock.Add(10 * time.Second)
+			err = st.Read(func(tx *bolster.Tx) error {
+				item := &structWithExpiry{Token: "unchanged"}
+				err := tx.Get(item, 2)
+				if e, ok := err.(bolster.Error); !ok || !e.IsNotFound() {
+					t.Errorf("expected ErrNotFound for expired item, got %v", err)
+				}
+				if item.Token != "unchanged" {
+					t.Errorf("expected item to be unchanged, got %+v", item)
+				}
+				var tokens []string
+				err = tx.Each(item, func() error {
+					tokens = append(tokens, item.Token)
+					return nil
+				})
+				if len(tokens) != 2 || tokens[0] != "never" || tokens[1] != "later" {
+					t.Errorf("expected Each to skip expired items, got %v", tokens)
+				}
+				return err
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			// expired items are replaced on insert and missing on update
+			err = st.Write(func(tx *bolster.Tx) error {
+				err := tx.Update(&structWithExpiry{ID: 2, Token: "update"})
+				if err == nil {
+					t.Error("expected updating an expired item to fail")
+				}
+				return nil
+			})
+			if err == nil {
+				t.Error("expected error, got nil")
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithExpiry{ID: 2, Token: "replaced"})
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		},
+		"DeleteExpired": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			n := bolster.ExpireBatchSize + 10
+			err := st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithExpiry{Token: "never"})
+				tx.Insert(&structWithExpiry{Token: "later", Expires: clock.Now().Add(time.Hour)})
+				for i := 0; i < n; i++ {
+					tx.Insert(&structWithExpiry{Token: "soon", Expires: clock.Now().Add(time.Duration(i) * time.Millisecond)})
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock.Add(time.Minute)
+			deleted, err := st.DeleteExpired()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != n {
+				t.Errorf("expected %d deleted items, got %d", n, deleted)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+		"DeleteExpiredUnmappedIDs": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			n := bolster.ExpireBatchSize
+			err := st.Write(func(tx *bolster.Tx) error {
+				for i := 0; i < n; i++ {
+					tx.Insert(&structWithStringIDAndExpiry{Key: fmt.Sprint(i), Expires: clock.Now()})
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// drop the mapping of string IDs to internal IDs
+			err = st.Bolt().Update(func(btx *bolt.Tx) error {
+				idx := btx.Bucket([]byte("github.com/nochso/bolster_test.structWithStringIDAndExpiry")).Bucket([]byte("index"))
+				err := idx.DeleteBucket([]byte("u, string Key"))
+				if err != nil {
+					return err
+				}
+				_, err = idx.CreateBucket([]byte("u, string Key"))
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			done := make(chan int)
+			go func() {
+				deleted, err := st.DeleteExpired()
+				if err != nil {
+					t.Error(err)
+				}
+				done <- deleted
+			}()
+			select {
+			case deleted := <-done:
+				if deleted != n {
+					t.Errorf("expected %d deleted items, got %d", n, deleted)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected DeleteExpired to finish")
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+		"StartExpirer": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			err := st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithExpiry{Token: "soon", Expires: clock.Now()})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			stop := st.StartExpirer(time.Millisecond)
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				count := 0
+				// bypass the expiry check of Get
+				err = st.Bolt().View(func(btx *bolt.Tx) error {
+					count = btx.Bucket([]byte("github.com/nochso/bolster_test.structWithExpiry")).Bucket([]byte("data")).Stats().KeyN
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if count == 0 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			err = stop()
+			if err != nil {
+				t.Error(err)
+			}
+			if time.Now().After(deadline) {
+				t.Error("expected expirer to delete the expired item")
+			}
+		},
+		"insertStringID": func(t *testing.T, st *bolster.Store, clock *internal.Clock) {
+			err := st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithStringIDAndExpiry{Key: "tok", Expires: clock.Now().Add(time.Second)})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithStringIDAndExpiry{Key: "tok"})
+			})
+			if err == nil {
+				t.Error("expected inserting an existing item to fail")
+			}
+			clock.Add(time.Second)
+			err = st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithStringIDAndExpiry{Key: "tok", Expires: clock.Now().Add(time.Hour)})
+				return tx.InsertMany([]structWithStringIDAndExpiry{{Key: "tok"}, {Key: "new"}})
+			})
+			if err == nil {
+				t.Error("expected inserting the replaced item again to fail")
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(&structWithStringIDAndExpiry{Key: "tok", Expires: clock.Now().Add(time.Hour)})
+			})
+			if err != nil {
+				t.Fatalf("expected expired item to be replaced, got %v", err)
+			}
+			clock.Add(time.Hour)
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.InsertMany([]structWithStringIDAndExpiry{{Key: "tok"}, {Key: "new"}})
+			})
+			if err != nil {
+				t.Fatalf("expected expired item to be replaced by InsertMany, got %v", err)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			clock := internal.SetTestClock(st)
+			err := st.Register(structWithExpiry{}, structWithStringIDAndExpiry{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st, clock)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package bolster
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -11,29 +12,103 @@ import (
 
 // Tx is a read-only or read-write transaction.
 type Tx struct {
-	store *Store
-	btx   *bolt.Tx
-	errs  *errlist.Errors // list of all errors so far
-	errf  Error           // error factory with context
+	store   *Store
+	btx     *bolt.Tx
+	errs    *errlist.Errors // list of all errors so far
+	errf    Error           // error factory with context
+	managed bool            // true when opened by Store.Read or Store.Write
+	resets  []func()        // revert changes made to items, see addReset
+	batch   bool            // true when called by Store.Batch
+	ctx     context.Context
+	depth   int            // amount of nested savepoints
+	undo    []func() error // revert writes made within savepoints
+	changes []typedChange  // sent to watchers after commit
+}
+
+func newTx(ctx context.Context, s *Store, btx *bolt.Tx, managed bool) *Tx {
+	return &Tx{btx: btx, store: s, errs: errlist.New(), managed: managed, ctx: ctx}
+}
+
+// Context returns the context of the transaction.
+//
+// Actions fail once the context is done. Write transactions are rolled back.
+func (tx *Tx) Context() context.Context {
+	return tx.ctx
+}
+
+// checkContext records and returns an error if the transaction's context is
+// done. If an action already failed because of it, that error is returned
+// instead of recording another one.
+func (tx *Tx) checkContext() error {
+	err := tx.ctx.Err()
+	if err == nil {
+		return nil
+	}
+	for _, e := range tx.errs.Unwrap() {
+		if errors.Is(e, err) {
+			return e
+		}
+	}
+	return tx.errs.Append(newErrorFactory(commit).with(err))
+}
+
+// Commit writes all changes of a transaction opened by Store.Begin.
+//
+// If any action of the transaction failed, the transaction is rolled back
+// instead and the errors are returned. If the context of the transaction is
+// done, only the error wrapping the context's error is returned.
+func (tx *Tx) Commit() error {
+	if tx.managed {
+		return ErrManagedTx
+	}
+	ctxErr := tx.checkContext()
+	if tx.errs.HasError() {
+		err := tx.btx.Rollback()
+		if err != nil {
+			tx.errs.Append(err)
+		}
+		if ctxErr != nil {
+			return ctxErr
+		}
+		return tx.errs.ErrorOrNil()
+	}
+	return tx.btx.Commit()
+}
+
+// Rollback discards all changes of a transaction opened by Store.Begin.
+func (tx *Tx) Rollback() error {
+	if tx.managed {
+		return ErrManagedTx
+	}
+	return tx.btx.Rollback()
 }
 
 type txAction int
 
-var txActionIndex = [...]uint8{0, 6, 12, 18, 21, 27, 35, 43}
+var txActionIndex = [...]uint8{0, 6, 12, 18, 21, 25, 31, 39, 47, 53, 60, 65, 72, 78, 83, 92, 100}
 
 const (
 	insert txAction = iota
 	update
 	upsert
 	get
+	each
 	delete
 	truncate
 	register
-	txActionNames = "insertupdateupsertgetdeletetruncateregister"
+	commit
+	restore
+	purge
+	history
+	revert
+	patch
+	increment
+	sequence
+	txActionNames = "insertupdateupsertgeteachdeletetruncateregistercommitrestorepurgehistoryrevertpatchincrementsequence"
 )
 
 func (a txAction) needsPointer() bool {
-	return a >= insert && a <= get
+	return a == insert || a >= upsert && a <= each || a == restore || a >= revert && a <= increment
 }
 
 func (a txAction) canAutoIncrement() bool {
@@ -68,7 +143,8 @@ func (tx *Tx) validateStruct(v interface{}, action txAction) (structType, reflec
 		return st, rv, fmt.Errorf("unregistered struct: %v", rt)
 	}
 	tx.errf = newErrorFactory(action, st)
-	return st, rv, nil
+	// abort early when the transaction is cancelled
+	return st, rv, tx.ctx.Err()
 }
 
 func (tx *Tx) addErr(err error) error {
@@ -92,13 +168,19 @@ func (tx *Tx) Truncate(v interface{}) error {
 	if err != nil {
 		return tx.addErr(err)
 	}
-	tx.addErr(tx.btx.DeleteBucket(st.FullName))
-	return tx.addErr(st.init(tx))
+	err = tx.truncate(st)
+	if err != nil {
+		return tx.addErr(err)
+	}
+	return tx.addErr(tx.recordChange(st, ChangeTruncate, reflect.Value{}, reflect.Value{}))
 }
 
 // Delete removes the given item.
 //
 // If the item does not exist a nil error is returned.
+//
+// Items of types with a field tagged `bolster:"deleted"` are only marked as
+// deleted, see Restore and Purge.
 func (tx *Tx) Delete(v interface{}) error {
 	if tx.errs.HasError() {
 		return tx.addErr(ErrBadTransaction)
@@ -107,215 +189,262 @@ func (tx *Tx) Delete(v interface{}) error {
 	if err != nil {
 		return tx.addErr(err)
 	}
-	bktData := tx.dataBkt(st)
-	idBytes, err := st.ID.encodeStruct(rv, tx.idxBkt(st), delete)
+	return tx.addErr(tx.remove(st, rv, v, true))
+}
+
+// remove deletes the item rv. Items of types with soft delete are only
+// marked as deleted if soft is true.
+func (tx *Tx) remove(st structType, rv reflect.Value, v interface{}, soft bool) error {
+	idBytes, err := st.ID.encodeStruct(tx, rv, tx.idxBkt(st), delete)
 	if err == ErrNotFound {
 		return nil
 	} else if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	oldStructBytes := bktData.Get(idBytes)
-	exists := oldStructBytes != nil
-	if !exists {
+	return tx.removeID(st, idBytes, v, soft)
+}
+
+// removeID deletes the item stored under the internal ID idBytes like remove.
+// v is passed to the delete hooks.
+func (tx *Tx) removeID(st structType, idBytes []byte, v interface{}, soft bool) error {
+	b := tx.dataBkt(st).Get(idBytes)
+	if b == nil {
 		return nil
 	}
-	err = bktData.Delete(idBytes)
-	if err != nil {
-		return tx.addErr(err)
-	}
 	// when deleting items stale index data needs to be removed
 	// index data currently reflects the data of the struct in the database,
 	// not the one being saved. we need to decode the old bytes into a
 	// struct and use it to delete the old index data.
-	old := reflect.New(rv.Type())
-	err = tx.store.codec.Unmarshal(oldStructBytes, old.Interface())
+	old, err := tx.decode(st, b)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	old = reflect.Indirect(old)
-	err = st.deleteIndexes(tx.idxBkt(st), old, idBytes)
-	return tx.addErr(err)
-}
-
-// Insert saves a new item.
-// If an item with the same ID exists an error is returned.
-func (tx *Tx) Insert(v interface{}) error {
-	st, rv, err := tx.validateStruct(v, insert)
-	if tx.errs.HasError() {
-		return tx.addErr(ErrBadTransaction)
+	soft = soft && st.Deleted != -1
+	if soft && st.isDeleted(old) {
+		return nil
 	}
+	err = tx.callBefore(v, delete)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	bktData := tx.dataBkt(st)
-	id := rv.Field(st.ID.StructPos)
-	if st.ID.AutoIncrement {
-		err = tx.autoincrement(id, bktData, st)
-		if err != nil {
-			return tx.addErr(err)
-		}
+	if soft {
+		err = tx.markDeleted(st, idBytes, old)
+	} else {
+		err = tx.purge(st, idBytes, old)
 	}
-	idBytes, err := st.ID.encodeStruct(rv, tx.idxBkt(st), insert)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	oldStructBytes := bktData.Get(idBytes)
-	exists := oldStructBytes != nil
-	if exists {
-		err = fmt.Errorf("item with ID %q already exists", fmt.Sprintf("%v", id.Interface()))
-		return tx.addErr(err)
+	return tx.callAfter(v, delete)
+}
+
+// purge deletes the stored item old and its index data.
+func (tx *Tx) purge(st structType, idBytes []byte, old reflect.Value) error {
+	err := tx.logHistory(st, idBytes, ChangeDelete)
+	if err != nil {
+		return err
 	}
-	structBytes, err := tx.store.codec.Marshal(v)
+	err = tx.delete(tx.dataBkt(st), idBytes)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	err = bktData.Put(idBytes, structBytes)
+	err = st.deleteIndexes(tx, tx.idxBkt(st), old, idBytes)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	err = st.putIndexes(tx.idxBkt(st), rv, idBytes)
-	return tx.addErr(err)
+	return tx.recordChange(st, ChangeDelete, old, reflect.Value{})
+}
+
+// Insert saves a new item.
+// If an item with the same ID exists an error is returned.
+func (tx *Tx) Insert(v interface{}) error {
+	return tx.write(v, insert)
 }
 
 // Update overwrites an existing item.
+// v must be a pointer to a struct if its type has a version field or
+// timestamps, as they are set on update. Otherwise a struct is accepted, too.
 //
 // If the item does not exist an error is returned.
+//
+// Structs with a field tagged `bolster:"version"` are only updated when the
+// field matches the stored item. The version is incremented on success and a
+// mismatch fails with ErrVersionConflict. The same applies to Upsert.
 func (tx *Tx) Update(v interface{}) error {
-	st, rv, err := tx.validateStruct(v, delete)
+	return tx.write(v, update)
+}
+
+// Upsert either updates or inserts an item.
+func (tx *Tx) Upsert(v interface{}) error {
+	return tx.write(v, upsert)
+}
+
+// write validates v and saves it according to the action.
+func (tx *Tx) write(v interface{}, action txAction) error {
+	st, rv, err := tx.validateStruct(v, action)
 	if tx.errs.HasError() {
 		return tx.addErr(ErrBadTransaction)
 	}
 	if err != nil {
 		return tx.addErr(err)
 	}
-	bktData := tx.dataBkt(st)
-	id := rv.Field(st.ID.StructPos)
-	idBytes, err := st.ID.encodeStruct(rv, tx.idxBkt(st), update)
+	if !rv.CanAddr() && st.setsFieldsOnUpdate() {
+		return tx.addErr(fmt.Errorf("expected pointer to struct, got %v: version and timestamp fields are set on update", rv.Kind()))
+	}
+	return tx.addErr(tx.save(st, rv, v, action))
+}
+
+// save writes the item and its index data.
+//
+// Whether the item may already exist depends on the action: insert fails for
+// existing items, update fails for missing ones and upsert accepts both.
+func (tx *Tx) save(st structType, rv reflect.Value, v interface{}, action txAction) error {
+	err := tx.callBefore(v, action)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	oldStructBytes := bktData.Get(idBytes)
-	exists := oldStructBytes != nil
-	if !exists {
-		err = fmt.Errorf("item with ID %q does not exist", fmt.Sprintf("%v", id.Interface()))
-		return tx.addErr(err)
+	if action.canAutoIncrement() {
+		err = tx.setSequences(st, rv)
+		if err != nil {
+			return err
+		}
 	}
-	structBytes, err := tx.store.codec.Marshal(v)
-	if err != nil {
-		return tx.addErr(err)
+	bktData := tx.dataBkt(st)
+	id := rv.Field(st.ID.StructPos)
+	if st.ID.AutoIncrement && action.canAutoIncrement() {
+		err = tx.autoincrement(id, bktData)
+		if err != nil {
+			return err
+		}
 	}
-	err = bktData.Put(idBytes, structBytes)
+	if action.canAutoIncrement() {
+		err = tx.generateID(st, id)
+		if err != nil {
+			return err
+		}
+	}
+	idBytes, err := st.ID.encodeStruct(tx, rv, tx.idxBkt(st), action)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
 	// index data currently reflects the data of the struct in the database,
 	// not the one being saved. we need to decode the old bytes into a
 	// struct and use it to delete the old index data.
-	old := reflect.New(rv.Type())
-	err = tx.store.codec.Unmarshal(oldStructBytes, old.Interface())
-	if err != nil {
-		return tx.addErr(err)
+	var stored, old reflect.Value
+	if b := bktData.Get(idBytes); b != nil {
+		stored, err = tx.decode(st, b)
+		if err != nil {
+			return err
+		}
+		switch {
+		case st.isDeleted(stored):
+			// soft deleted items are missing for updates but must not be
+			// overwritten by accident
+			if action != update {
+				return fmt.Errorf("item with ID %q has been deleted and must be restored or purged first", st.ID.formatStruct(rv))
+			}
+		case !st.Expiry.isExpired(stored, tx.store.now):
+			// expired items are replaced as if they did not exist
+			old = stored
+		}
 	}
-	old = reflect.Indirect(old)
-	err = st.deleteIndexes(tx.idxBkt(st), old, idBytes)
-	if err != nil {
-		return tx.addErr(err)
+	exists := old.IsValid()
+	if exists && action == insert {
+		return fmt.Errorf("item with ID %q already exists", st.ID.formatStruct(rv))
 	}
-	err = st.putIndexes(tx.idxBkt(st), rv, idBytes)
-	return tx.addErr(err)
-}
-
-// Upsert either updates or inserts an item.
-func (tx *Tx) Upsert(v interface{}) error {
-	st, rv, err := tx.validateStruct(v, upsert)
-	if tx.errs.HasError() {
-		return tx.addErr(ErrBadTransaction)
+	if !exists && action == update {
+		return fmt.Errorf("item with ID %q does not exist", st.ID.formatStruct(rv))
 	}
+	err = tx.setVersion(st, rv, old)
 	if err != nil {
-		return tx.addErr(err)
-	}
-	bktData := tx.dataBkt(st)
-	id := rv.Field(st.ID.StructPos)
-	if st.ID.AutoIncrement {
-		err = tx.autoincrement(id, bktData, st)
-		if err != nil {
-			return tx.addErr(err)
-		}
+		return err
 	}
-	idBytes, err := st.ID.encodeStruct(rv, tx.idxBkt(st), upsert)
+	tx.setTimestamps(st, rv, old)
+	err = st.validate(rv, v)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	oldStructBytes := bktData.Get(idBytes)
-	exists := oldStructBytes != nil
 	structBytes, err := tx.store.codec.Marshal(v)
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	err = bktData.Put(idBytes, structBytes)
+	err = tx.logHistory(st, idBytes, ChangeType(action))
 	if err != nil {
-		return tx.addErr(err)
+		return err
 	}
-	if exists {
-		// index data currently reflects the data of the struct in the database,
-		// not the one being saved. we need to decode the old bytes into a
-		// struct and use it to delete the old index data.
-		old := reflect.New(rv.Type())
-		err = tx.store.codec.Unmarshal(oldStructBytes, old.Interface())
-		if err != nil {
-			return tx.addErr(err)
-		}
-		old = reflect.Indirect(old)
-		err = st.deleteIndexes(tx.idxBkt(st), old, idBytes)
-		if err != nil {
-			return tx.addErr(err)
-		}
+	err = tx.put(bktData, idBytes, structBytes)
+	if err != nil {
+		return err
 	}
-	err = st.putIndexes(tx.idxBkt(st), rv, idBytes)
-	return tx.addErr(err)
+	err = st.updateIndexes(tx, tx.idxBkt(st), stored, rv, idBytes)
+	if err != nil {
+		return err
+	}
+	err = tx.recordChange(st, ChangeType(action), old, rv)
+	if err != nil {
+		return err
+	}
+	return tx.callAfter(v, action)
 }
 
-func (tx *Tx) autoincrement(id reflect.Value, bkt *bolt.Bucket, st structType) error {
-	idType := id.Type()
-	zero := reflect.Zero(idType).Interface()
-	if id.Interface() != zero {
+// decode returns a new struct of type st decoded from b.
+func (tx *Tx) decode(st structType, b []byte) (reflect.Value, error) {
+	rv := reflect.New(st.Type)
+	err := tx.store.codec.Unmarshal(b, rv.Interface())
+	return rv.Elem(), err
+}
+
+// autoincrement sets the integer field f to the next sequence of bkt if it is
+// zero.
+func (tx *Tx) autoincrement(f reflect.Value, bkt *bolt.Bucket) error {
+	fType := f.Type()
+	zero := reflect.Zero(fType)
+	if f.Interface() != zero.Interface() {
 		return nil
 	}
-	seq, err := bkt.NextSequence()
+	seq, err := tx.nextSequence(bkt)
 	if err != nil {
 		return err
 	}
 	seqRV := reflect.ValueOf(seq)
-	if !seqRV.Type().ConvertibleTo(idType) {
-		return fmt.Errorf("unable to convert autoincremented ID of type %s to %s", seqRV.Type(), idType)
+	if !seqRV.Type().ConvertibleTo(fType) {
+		return fmt.Errorf("unable to convert autoincremented value of type %s to %s", seqRV.Type(), fType)
 	}
 	var overflows bool
-	if idType.Kind() >= reflect.Int && idType.Kind() <= reflect.Int64 {
+	if fType.Kind() >= reflect.Int && fType.Kind() <= reflect.Int64 {
 		signedSeq := int64(seq)
-		overflows = id.OverflowInt(signedSeq)
-	} else if idType.Kind() >= reflect.Uint && idType.Kind() <= reflect.Uint64 {
-		overflows = id.OverflowUint(seq)
+		overflows = f.OverflowInt(signedSeq)
+	} else if fType.Kind() >= reflect.Uint && fType.Kind() <= reflect.Uint64 {
+		overflows = f.OverflowUint(seq)
 	}
 	if overflows {
-		return fmt.Errorf("next bucket sequence %d overflows ID field of type %s", seq, idType)
+		return fmt.Errorf("next bucket sequence %d overflows field of type %s", seq, fType)
 	}
-	id.Set(seqRV.Convert(idType))
+	f.Set(seqRV.Convert(fType))
+	tx.addReset(func() { f.Set(zero) })
 	return nil
 }
 
 // Get fetches an item of v's type by its ID.
 // v must be a pointer to a struct.
+//
+// Composite IDs are passed as a Key, e.g. tx.Get(v, Key{tenantID, slug}),
+// rather than as separate arguments. Patch and Increment take further
+// arguments after the ID, so a single ID argument keeps all methods taking
+// an ID alike.
+//
+// Expired items are not found even if they have not been deleted yet.
+// Neither are soft deleted items.
 func (tx *Tx) Get(v interface{}, id interface{}) error {
-	st, _, err := tx.validateStruct(v, get)
+	st, rv, err := tx.validateStruct(v, get)
 	if err != nil {
 		return tx.errf.with(err)
 	}
-	actTypeID := reflect.TypeOf(id)
-	expTypeID := st.ID.Type
-	if actTypeID != expTypeID {
-		return tx.errf.with(fmt.Errorf("incompatible type of ID: expected %v, got %v", expTypeID, actTypeID))
+	values, err := st.idValues(id)
+	if err != nil {
+		return tx.errf.with(err)
 	}
-	idBytes, err := st.ID.encode(id, tx.idxBkt(st), get)
+	idBytes, err := st.ID.encode(tx, values, tx.idxBkt(st), get)
 	if err != nil {
 		return tx.errf.with(err)
 	}
@@ -324,5 +453,61 @@ func (tx *Tx) Get(v interface{}, id interface{}) error {
 	if b == nil {
 		return tx.errf.with(ErrNotFound)
 	}
-	return tx.errf.with(tx.store.codec.Unmarshal(b, v))
+	errf := tx.errf
+	if st.canHide() {
+		// v must not be changed when the item is hidden
+		item, err := tx.decode(st, b)
+		if err != nil {
+			return errf.with(err)
+		}
+		if st.isHidden(item, tx.store.now) {
+			return errf.with(ErrNotFound)
+		}
+		rv.Set(item)
+	} else {
+		err = tx.store.codec.Unmarshal(b, v)
+		if err != nil {
+			return errf.with(err)
+		}
+	}
+	return errf.with(tx.callAfter(v, get))
+}
+
+// Each calls fn for every item of v's type in order of their IDs.
+// v must be a pointer to a struct. Each item is decoded into v before fn is
+// called. Expired and soft deleted items are skipped.
+//
+// Iteration stops at the first error returned by fn, which is then returned
+// from Each. Iteration also stops when the transaction's context is done.
+func (tx *Tx) Each(v interface{}, fn func() error) error {
+	st, rv, err := tx.validateStruct(v, each)
+	if err != nil {
+		return tx.errf.with(err)
+	}
+	errf := tx.errf
+	c := tx.dataBkt(st).Cursor()
+	for k, b := c.First(); k != nil; k, b = c.Next() {
+		err = tx.ctx.Err()
+		if err != nil {
+			return errf.with(err)
+		}
+		// v must not hold hidden items, not even after the last call of fn
+		item, err := tx.decode(st, b)
+		if err != nil {
+			return errf.with(err)
+		}
+		if st.isHidden(item, tx.store.now) {
+			continue
+		}
+		rv.Set(item)
+		err = tx.callAfter(v, get)
+		if err != nil {
+			return errf.with(err)
+		}
+		err = fn()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,7 +1,10 @@
 package bolster_test
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -130,6 +133,30 @@ func TestTx_Insert_withoutAutoincrement(t *testing.T) {
 	})
 }
 
+type structWithFoldedIndex struct {
+	ID    int
+	Email string `bolster:"index,fold"`
+}
+
+func TestTx_Insert_withCollation(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithFoldedIndex{})
+	if err != nil {
+		t.Error(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.Insert(&structWithFoldedIndex{ID: 1, Email: "Alice@Example.com"})
+		tx.Insert(&structWithFoldedIndex{ID: 2, Email: "alice@example.com"})
+		tx.Insert(&structWithFoldedIndex{ID: 3, Email: "BOB@example.com"})
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	internal.GoldStore(t, st, *updateGold)
+}
+
 func TestTx_Insert_NonIntegerID(t *testing.T) {
 	t.Run("single", func(t *testing.T) {
 		st, closer := internal.OpenTestStore(t)
@@ -460,6 +487,48 @@ func TestTx_Get(t *testing.T) {
 	})
 }
 
+type structWithFoldedID struct {
+	Email string `bolster:"id,fold,unaccent"`
+}
+
+func TestTx_Get_withCollation(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithFoldedID{})
+	if err != nil {
+		t.Error(err)
+	}
+	exp := &structWithFoldedID{Email: "Zoë@Example.com"}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(exp)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	t.Run("Success", func(t *testing.T) {
+		act := &structWithFoldedID{}
+		err := st.Read(func(tx *bolster.Tx) error {
+			return tx.Get(act, "ZOE@EXAMPLE.COM")
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(act, exp) {
+			t.Error(pretty.Compare(act, exp))
+		}
+	})
+	t.Run("duplicate", func(t *testing.T) {
+		err := st.Write(func(tx *bolster.Tx) error {
+			return tx.Insert(&structWithFoldedID{Email: "zoe@example.com"})
+		})
+		if err == nil {
+			t.Error("expected error, got nil")
+		} else {
+			t.Log(err)
+		}
+	})
+}
+
 func TestTx_Delete(t *testing.T) {
 	st, closer := internal.OpenTestStore(t)
 	defer closer()
@@ -592,6 +661,40 @@ func TestTx_Update(t *testing.T) {
 		}
 		internal.GoldStore(t, st, *updateGold)
 	})
+	t.Run("struct", func(t *testing.T) {
+		err = st.Write(func(tx *bolster.Tx) error {
+			tx.Insert(&structWithIDAndField{2, "foo"})
+			return tx.Update(structWithIDAndField{2, "bar"})
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		act := &structWithIDAndField{}
+		err = st.Read(func(tx *bolster.Tx) error {
+			return tx.Get(act, 2)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if act.Name != "bar" {
+			t.Errorf("expected updated item, got %+v", act)
+		}
+	})
+	t.Run("structWithTimestamps", func(t *testing.T) {
+		err := st.Register(structWithTimestamps{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = st.Write(func(tx *bolster.Tx) error {
+			tx.Insert(&structWithTimestamps{Name: "foo"})
+			return tx.Update(structWithTimestamps{ID: 1, Name: "bar"})
+		})
+		if err == nil {
+			t.Error("expected error for struct whose timestamps are set, got nil")
+		} else {
+			t.Log(err)
+		}
+	})
 }
 
 type structWithIncrementingIDAndField struct {
@@ -731,3 +834,197 @@ func TestTx_Write_errors(t *testing.T) {
 		t.Log(err)
 	}
 }
+
+func BenchmarkTx_Insert(b *testing.B) {
+	st, closer := internal.OpenTestStore(b)
+	defer closer()
+	err := st.Register(structWithMultiFieldIndex{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	err = st.Write(func(tx *bolster.Tx) error {
+		for i := 0; i < b.N; i++ {
+			err := tx.Insert(&structWithMultiFieldIndex{ID: i, Name: "foo", Visible: i%2 == 0})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+	}
+}
+
+func TestTx_Each(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		for _, id := range []int{3, -1, 2, 1} {
+			tx.Insert(&structWithIDAndField{id, fmt.Sprint(id)})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("all", func(t *testing.T) {
+		var act []structWithIDAndField
+		err := st.Read(func(tx *bolster.Tx) error {
+			item := &structWithIDAndField{}
+			return tx.Each(item, func() error {
+				act = append(act, *item)
+				return nil
+			})
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		exp := []structWithIDAndField{{-1, "-1"}, {1, "1"}, {2, "2"}, {3, "3"}}
+		if !reflect.DeepEqual(act, exp) {
+			t.Error(pretty.Compare(act, exp))
+		}
+	})
+	t.Run("stop", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		err := st.Read(func(tx *bolster.Tx) error {
+			return tx.Each(&structWithIDAndField{}, func() error {
+				calls++
+				return stop
+			})
+		})
+		if err != stop {
+			t.Errorf("expected error %v, got %v", stop, err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+	t.Run("withoutPointer", func(t *testing.T) {
+		err := st.Read(func(tx *bolster.Tx) error {
+			return tx.Each(structWithIDAndField{}, func() error { return nil })
+		})
+		if err == nil {
+			t.Error("expected error, got nil")
+		} else {
+			t.Log(err)
+		}
+	})
+}
+
+func TestTx_context(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithIDAndField{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		for id := 1; id <= 5; id++ {
+			tx.Insert(&structWithIDAndField{ID: id})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Each", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		calls := 0
+		err := st.ReadContext(ctx, func(tx *bolster.Tx) error {
+			return tx.Each(&structWithIDAndField{}, func() error {
+				calls++
+				if calls == 2 {
+					cancel()
+				}
+				return nil
+			})
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 calls, got %d", calls)
+		}
+	})
+	t.Run("WriteContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := st.WriteContext(ctx, func(tx *bolster.Tx) error {
+			tx.Insert(&structWithIDAndField{ID: 6})
+			cancel()
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		} else {
+			t.Log(err)
+		}
+		err = st.Read(func(tx *bolster.Tx) error {
+			return tx.Get(&structWithIDAndField{}, 6)
+		})
+		if e, ok := err.(bolster.Error); !ok || !e.IsNotFound() {
+			t.Errorf("expected cancelled write to be rolled back, got %v", err)
+		}
+	})
+	t.Run("WriteContextAction", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := st.WriteContext(ctx, func(tx *bolster.Tx) error {
+			cancel()
+			return tx.Insert(&structWithIDAndField{ID: 7})
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+	t.Run("WriteContextIgnoredAction", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := st.WriteContext(ctx, func(tx *bolster.Tx) error {
+			cancel()
+			tx.Insert(&structWithIDAndField{ID: 7})
+			return nil
+		})
+		// a single error, not a list, so that errors.Is works before Go 1.20
+		if _, ok := err.(bolster.Error); !ok || !errors.Is(err, context.Canceled) {
+			t.Errorf("expected single error wrapping context.Canceled, got %v", err)
+		}
+	})
+	t.Run("BeginContext", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		tx, err := st.BeginContext(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tx.Insert(&structWithIDAndField{ID: 8})
+		if err != nil {
+			t.Error(err)
+		}
+		cancel()
+		err = tx.Commit()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+	t.Run("alreadyCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		called := false
+		err := st.WriteContext(ctx, func(tx *bolster.Tx) error {
+			called = true
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if called {
+			t.Error("expected function not to be called")
+		}
+	})
+}
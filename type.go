@@ -7,16 +7,27 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/nochso/bolster/bytesort"
 )
 
+var timeType = reflect.TypeOf(time.Time{})
+
 type structType struct {
-	FullName []byte
-	ID       idField
-	Type     reflect.Type
-	Indexes  []index
+	FullName   []byte
+	ID         idField
+	Type       reflect.Type
+	Indexes    []index
+	Rules      []fieldRule
+	Timestamps timestampFields
+	Version    int // position of the version field or -1
+	Expiry     expiryField
+	Deleted    int  // position of the soft delete field or -1
+	History    bool // keep previous versions, see Store.EnableHistory
+	Sequences  []sequenceField
 }
 
 func newStructType(t reflect.Type) (structType, error) {
@@ -33,11 +44,39 @@ func newStructType(t reflect.Type) (structType, error) {
 	if err != nil {
 		return *st, err
 	}
+	st.Expiry, st.Indexes, err = newExpiryField(t, newStructTagList(t), st.Indexes)
+	if err != nil {
+		return *st, err
+	}
+	st.Deleted, st.Indexes, err = newDeletedField(t, newStructTagList(t), st.Indexes)
+	if err != nil {
+		return *st, err
+	}
+	st.Rules, err = newFieldRules(t, newStructTagList(t))
+	if err != nil {
+		return *st, err
+	}
+	st.Timestamps, err = newTimestampFields(t, newStructTagList(t))
+	if err != nil {
+		return *st, err
+	}
+	st.Version, err = newVersionField(t, newStructTagList(t))
+	if err != nil {
+		return *st, err
+	}
+	st.Sequences, err = newSequenceFields(t, newStructTagList(t), st.ID)
+	if err != nil {
+		return *st, err
+	}
 	if !st.ID.isInteger() {
-		// non-integer IDs need to be uniquely mapped to uint64 IDs
-		idx := index{
-			Unique: true,
-			Fields: []indexField{{st.ID.StructPos, st.ID.StructField}},
+		// non-integer and composite IDs need to be uniquely mapped to uint64 IDs
+		idx := index{Unique: true}
+		for _, pos := range st.ID.positions() {
+			f, err := newIndexField(t, newStructTagList(t), pos)
+			if err != nil {
+				return *st, err
+			}
+			idx.Fields = append(idx.Fields, f)
 		}
 		idx.FullName = idx.getFullName()
 		st.ID.IntIndex = idx
@@ -48,12 +87,14 @@ func newStructType(t reflect.Type) (structType, error) {
 }
 
 func (st *structType) validateBytesort() error {
-	zv := reflect.Zero(st.ID.Type)
-	_, err := bytesort.Encode(zv.Interface())
-	if err != nil {
-		err = fmt.Errorf("ID field %q is not byte encodable: %s", st.ID.Name, err)
+	for _, pos := range st.ID.positions() {
+		f := st.Type.Field(pos)
+		_, err := bytesort.Encode(reflect.Zero(f.Type).Interface())
+		if err != nil {
+			return fmt.Errorf("ID field %q is not byte encodable: %s", f.Name, err)
+		}
 	}
-	return err
+	return nil
 }
 
 func (st structType) String() string {
@@ -65,7 +106,7 @@ func (st structType) init(tx *Tx) error {
 	if err != nil {
 		return err
 	}
-	_, err = bkt.CreateBucketIfNotExists(bktNameData)
+	dataBkt, err := bkt.CreateBucketIfNotExists(bktNameData)
 	if err != nil {
 		return err
 	}
@@ -73,8 +114,43 @@ func (st structType) init(tx *Tx) error {
 	if err != nil {
 		return err
 	}
+	err = st.deleteStaleIndexes(idxBkt)
+	if err != nil {
+		return err
+	}
+	var created []index
+	for _, idx := range st.Indexes {
+		if idxBkt.Bucket(idx.FullName) != nil {
+			continue
+		}
+		_, err = idxBkt.CreateBucket(idx.FullName)
+		if err != nil {
+			return err
+		}
+		created = append(created, idx)
+	}
+	return st.buildIndexes(tx, dataBkt, idxBkt, created)
+}
+
+// deleteStaleIndexes removes index buckets that are not part of the struct
+// definition anymore.
+//
+// Index names contain the type and name of each field as well as the encoding
+// version of time fields. Changing any of these results in a new index.
+func (st structType) deleteStaleIndexes(idxBkt *bolt.Bucket) error {
+	known := make(map[string]bool, len(st.Indexes))
 	for _, idx := range st.Indexes {
-		_, err = idxBkt.CreateBucketIfNotExists(idx.FullName)
+		known[string(idx.FullName)] = true
+	}
+	var stale [][]byte
+	c := idxBkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v == nil && !known[string(k)] {
+			stale = append(stale, cloneBytes(k))
+		}
+	}
+	for _, name := range stale {
+		err := idxBkt.DeleteBucket(name)
 		if err != nil {
 			return err
 		}
@@ -82,9 +158,32 @@ func (st structType) init(tx *Tx) error {
 	return nil
 }
 
-func (st structType) putIndexes(bkt *bolt.Bucket, rv reflect.Value, id []byte) error {
+// buildIndexes populates new indexes from the existing items.
+func (st structType) buildIndexes(tx *Tx, dataBkt, idxBkt *bolt.Bucket, indexes []index) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	c := dataBkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		rv := reflect.New(st.Type)
+		err := tx.store.codec.Unmarshal(v, rv.Interface())
+		if err != nil {
+			return err
+		}
+		rv = rv.Elem()
+		for _, idx := range indexes {
+			err = idx.put(tx, idxBkt, rv, k)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (st structType) deleteIndexes(tx *Tx, bkt *bolt.Bucket, rv reflect.Value, id []byte) error {
 	for _, idx := range st.Indexes {
-		err := idx.put(bkt, rv, id)
+		err := idx.delete(tx, bkt, rv, id)
 		if err != nil {
 			return err
 		}
@@ -92,9 +191,25 @@ func (st structType) putIndexes(bkt *bolt.Bucket, rv reflect.Value, id []byte) e
 	return nil
 }
 
-func (st structType) deleteIndexes(bkt *bolt.Bucket, rv reflect.Value, id []byte) error {
+// updateIndexes replaces the index data of the stored item old with that of
+// rv. Indexes whose fields are unchanged are left as they are. old may be
+// invalid if there is no stored item.
+func (st structType) updateIndexes(tx *Tx, bkt *bolt.Bucket, old, rv reflect.Value, id []byte) error {
 	for _, idx := range st.Indexes {
-		err := idx.delete(bkt, rv, id)
+		if old.IsValid() {
+			changed, err := idx.changed(old, rv)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				continue
+			}
+			err = idx.delete(tx, bkt, old, id)
+			if err != nil {
+				return err
+			}
+		}
+		err := idx.put(tx, bkt, rv, id)
 		if err != nil {
 			return err
 		}
@@ -102,61 +217,147 @@ func (st structType) deleteIndexes(bkt *bolt.Bucket, rv reflect.Value, id []byte
 	return nil
 }
 
+// Key is a composite ID. It holds one value per ID field in the order of
+// their tags `bolster:"id <position>"`.
+//
+// Composite IDs are passed as a Key to every method taking an ID, e.g.
+// tx.Get(&page, bolster.Key{tenantID, slug}). Other IDs are passed as is.
+type Key []interface{}
+
 type idField struct {
-	StructPos int
+	StructPos int // position of the ID field or the first field of a composite ID
 	reflect.StructField
+	Composite     []int // positions of the fields of a composite ID in key order
 	AutoIncrement bool
-	IntIndex      index // non-integer type mapping to uint64
+	Generator     idGenerator
+	IntIndex      index // non-integer or composite ID mapping to uint64
 }
 
 func (i idField) isInteger() bool {
-	return i.Type.Kind() >= reflect.Int && i.Type.Kind() <= reflect.Uint64
+	return i.Composite == nil && i.Type.Kind() >= reflect.Int && i.Type.Kind() <= reflect.Uint64
+}
+
+// positions returns the positions of all ID fields in key order.
+func (i idField) positions() []int {
+	if i.Composite != nil {
+		return i.Composite
+	}
+	return []int{i.StructPos}
+}
+
+// contains returns true if the field at pos is part of the ID.
+func (i idField) contains(pos int) bool {
+	for _, p := range i.positions() {
+		if p == pos {
+			return true
+		}
+	}
+	return false
+}
+
+// values returns the ID of the struct rv.
+func (i idField) values(rv reflect.Value) []interface{} {
+	pos := i.positions()
+	v := make([]interface{}, len(pos))
+	for n, p := range pos {
+		v[n] = rv.Field(p).Interface()
+	}
+	return v
 }
 
-func (i idField) encode(v interface{}, bkt *bolt.Bucket, a txAction) ([]byte, error) {
-	f := reflect.ValueOf(v)
+// format returns a readable form of the ID values v for error messages.
+func (i idField) format(v []interface{}) string {
+	if len(v) == 1 {
+		return fmt.Sprintf("%v", v[0])
+	}
+	parts := make([]string, len(v))
+	for n := range v {
+		parts[n] = fmt.Sprintf("%v", v[n])
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// formatStruct returns a readable form of the ID of the struct rv.
+func (i idField) formatStruct(rv reflect.Value) string {
+	return i.format(i.values(rv))
+}
+
+// idValues returns the values of an ID passed to an action. An error is
+// returned if they do not match the types of the ID fields.
+func (st structType) idValues(id interface{}) ([]interface{}, error) {
+	key, isKey := id.(Key)
+	if st.ID.Composite == nil {
+		if act := reflect.TypeOf(id); act != st.ID.Type {
+			return nil, fmt.Errorf("incompatible type of ID: expected %v, got %v", st.ID.Type, act)
+		}
+		return []interface{}{id}, nil
+	}
+	if !isKey {
+		return nil, fmt.Errorf("composite ID must be passed as %T, got %T", key, id)
+	}
+	pos := st.ID.positions()
+	if len(key) != len(pos) {
+		return nil, fmt.Errorf("expected %d ID values, got %d", len(pos), len(key))
+	}
+	for n, p := range pos {
+		f := st.Type.Field(p)
+		if act := reflect.TypeOf(key[n]); act != f.Type {
+			return nil, fmt.Errorf("incompatible type of ID field %q: expected %v, got %v", f.Name, f.Type, act)
+		}
+	}
+	return key, nil
+}
+
+func (i idField) encode(tx *Tx, v []interface{}, bkt *bolt.Bucket, a txAction) ([]byte, error) {
 	if i.isInteger() {
 		// always encode integer IDs with 8 bytes length
-		if f.Type().Size() < 8 {
-			k := f.Type().Kind()
-			if k >= reflect.Int && k <= reflect.Int64 {
-				f = f.Convert(reflect.TypeOf(int64(0)))
-			} else {
-				f = f.Convert(reflect.TypeOf(uint64(0)))
-			}
+		f := reflect.ValueOf(v[0])
+		b := make([]byte, 0, 8)
+		if k := f.Kind(); k >= reflect.Int && k <= reflect.Int64 {
+			return bytesort.AppendInt64(b, f.Int()), nil
 		}
-		return bytesort.Encode(f.Interface())
+		return bytesort.AppendUint64(b, f.Uint()), nil
 	}
-	// non-integer IDs need to be mapped to uint64
-	b, err := i.IntIndex.get(bkt, v)
+	// non-integer and composite IDs need to be mapped to uint64
+	b, err := i.IntIndex.get(bkt, v...)
 	if err == ErrNotFound {
 		if a != insert && a != upsert {
 			return nil, err
 		}
 		// ID is unknown but we're inserting or upserting so get the next ID
 		var id uint64
-		id, err = bkt.NextSequence()
+		id, err = tx.nextSequence(bkt)
 		if err != nil {
 			return nil, err
 		}
-		return bytesort.Encode(id)
-	}
-	if a == insert {
-		return nil, fmt.Errorf("item with ID %q already exists", fmt.Sprintf("%v", v))
+		return bytesort.AppendUint64(make([]byte, 0, 8), id), nil
 	}
+	// whether an insert conflicts with the stored item is up to the caller,
+	// e.g. expired items may be replaced
 	return b, err
 }
 
-func (i idField) encodeStruct(structRV reflect.Value, bkt *bolt.Bucket, a txAction) ([]byte, error) {
-	return i.encode(structRV.Field(i.StructPos).Interface(), bkt, a)
+func (i idField) encodeStruct(tx *Tx, structRV reflect.Value, bkt *bolt.Bucket, a txAction) ([]byte, error) {
+	return i.encode(tx, i.values(structRV), bkt, a)
 }
 
 func newIDField(t reflect.Type) (idField, error) {
 	id := idField{StructPos: -1}
 	stl := newStructTagList(t)
 	idKeys := stl.filter(tagID)
+	composite, err := newCompositeID(stl)
+	if err != nil {
+		return id, err
+	}
 	if len(idKeys) > 1 {
 		return id, fmt.Errorf("must not have multiple fields with tag %q", tagID)
+	} else if len(idKeys) == 1 && composite != nil {
+		return id, fmt.Errorf("must not mix tag %q with %q", tagID, tagID+" <position>")
+	} else if len(composite) == 1 {
+		id.StructPos = composite[0]
+	} else if composite != nil {
+		id.StructPos = composite[0]
+		id.Composite = composite
 	} else if len(idKeys) == 1 {
 		id.StructPos = idKeys[0]
 	} else if idField, ok := t.FieldByName("ID"); ok {
@@ -167,10 +368,52 @@ func newIDField(t reflect.Type) (idField, error) {
 	}
 	id.StructField = t.Field(id.StructPos)
 	id.AutoIncrement = stl.contains(id.StructPos, tagAutoIncrement)
+	if id.Composite != nil && id.AutoIncrement {
+		return id, errors.New("composite IDs can not be autoincremented")
+	}
 	if !id.isInteger() && id.AutoIncrement {
 		return id, fmt.Errorf("autoincremented IDs must be integer, got %s", id.Type.Kind())
 	}
-	return id, nil
+	id.Generator, err = newIDGenerator(t, stl, id)
+	if err == nil && id.Composite != nil && id.Generator != noGenerator {
+		err = errors.New("composite IDs can not be generated")
+	}
+	return id, err
+}
+
+// newCompositeID returns the positions of fields tagged `bolster:"id <pos>"`
+// in the order given by their tags.
+func newCompositeID(stl structTagList) ([]int, error) {
+	positions := make(map[int]int)
+	for fieldPos, tags := range stl {
+		for _, tag := range tags {
+			// id <position of field in ID>
+			words := strings.Fields(tag)
+			if len(words) != 2 || words[0] != tagID {
+				continue
+			}
+			idPos, err := strconv.Atoi(words[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid tag %q: %s", tag, err)
+			}
+			if _, ok := positions[idPos]; ok {
+				return nil, fmt.Errorf("must not have multiple fields with tag %q", tag)
+			}
+			positions[idPos] = fieldPos
+		}
+	}
+	if len(positions) == 0 {
+		return nil, nil
+	}
+	composite := make([]int, len(positions))
+	for i := range composite {
+		fieldPos, ok := positions[i]
+		if !ok {
+			return nil, fmt.Errorf("composite ID has %d field(s) and its field order must be 0..%d: field %d is missing", len(positions), len(positions)-1, i)
+		}
+		composite[i] = fieldPos
+	}
+	return composite, nil
 }
 
 func newIndexSlice(t reflect.Type) ([]index, error) {
@@ -185,7 +428,11 @@ func newIndexSlice(t reflect.Type) ([]index, error) {
 			}
 			if words[0] == tagIndex {
 				if len(words) == 1 {
-					idx := index{Fields: []indexField{{fieldPos, t.Field(fieldPos)}}}
+					f, err := newIndexField(t, stl, fieldPos)
+					if err != nil {
+						return nil, err
+					}
+					idx := index{Fields: []indexField{f}}
 					idx.FullName = idx.getFullName()
 					is = append(is, idx)
 				} else if len(words) == 3 {
@@ -211,7 +458,10 @@ func newIndexSlice(t reflect.Type) ([]index, error) {
 				err := fmt.Errorf("index %q has %d field(s) and its field order must be 0..%d: field %d is missing", idxID, len(positions), len(positions)-1, i)
 				return nil, err
 			}
-			f := indexField{fieldPos, t.Field(fieldPos)}
+			f, err := newIndexField(t, stl, fieldPos)
+			if err != nil {
+				return nil, err
+			}
 			idx.Fields = append(idx.Fields, f)
 		}
 		idx.FullName = idx.getFullName()
@@ -235,6 +485,15 @@ func (i index) getFullName() []byte {
 	}
 	for _, field := range i.Fields {
 		fmt.Fprintf(buf, ",%s %s %s", field.Type.PkgPath(), field.Type, field.Name)
+		if field.Collation != 0 {
+			fmt.Fprintf(buf, " %s", field.Collation)
+		}
+		if field.Type == timeType {
+			fmt.Fprintf(buf, " v%d", bytesort.TimeEncodingVersion)
+		}
+		if k := field.Type.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+			fmt.Fprintf(buf, " v%d", bytesort.FloatEncodingVersion)
+		}
 	}
 	return buf.Bytes()
 }
@@ -247,81 +506,160 @@ func (i index) get(bkt *bolt.Bucket, v ...interface{}) ([]byte, error) {
 		return nil, errors.New("amount of values does not match count of index fields")
 	}
 	bkt = bkt.Bucket(i.FullName)
-	key := &bytes.Buffer{}
+	buf := getKeyBuf()
+	defer putKeyBuf(buf)
+	key := (*buf)[:0]
 	for n, field := range i.Fields {
-		b, err := bytesort.Encode(v[n])
+		var err error
+		key, err = field.append(key, v[n])
 		if err != nil {
 			return nil, err
 		}
-		key.Write(b)
 		if field.Type.Kind() == reflect.String && n < len(i.Fields)-1 {
-			bkt = bkt.Bucket(key.Bytes())
+			bkt = bkt.Bucket(key)
 			if bkt == nil {
 				return nil, ErrNotFound
 			}
-			key.Reset()
+			key = key[:0]
 		}
 	}
-	b := bkt.Get(key.Bytes())
+	*buf = key
+	b := bkt.Get(key)
 	if b == nil {
 		return nil, ErrNotFound
 	}
 	return b, nil
 }
 
-func (i index) put(bkt *bolt.Bucket, rv reflect.Value, id []byte) error {
+func (i index) put(tx *Tx, bkt *bolt.Bucket, rv reflect.Value, id []byte) error {
 	bkt = bkt.Bucket(i.FullName)
-	key := &bytes.Buffer{}
+	buf := getKeyBuf()
+	defer putKeyBuf(buf)
+	key := (*buf)[:0]
 	for n, field := range i.Fields {
-		b, err := bytesort.Encode(rv.Field(field.StructPos).Interface())
+		var err error
+		key, err = field.append(key, rv.Field(field.StructPos).Interface())
 		if err != nil {
 			return err
 		}
-		key.Write(b)
 		if field.Type.Kind() == reflect.String && n < len(i.Fields)-1 {
-			bkt, err = bkt.CreateBucketIfNotExists(key.Bytes())
+			// bucket names are copied by bolt and the undo log, the key can
+			// be reused
+			bkt, err = tx.createBucket(bkt, key)
 			if err != nil {
 				return err
 			}
-			key.Reset()
+			key = key[:0]
 		}
 	}
+	if !i.Unique {
+		key = append(key, id...)
+	}
+	*buf = key
+	// bolt keeps a reference to the key until the transaction is closed, so it
+	// must not be backed by the reusable buffer.
 	if i.Unique {
 		// Key -> value (value being the primary ID)
-		return bkt.Put(key.Bytes(), id)
+		return tx.put(bkt, cloneBytes(key), id)
 	}
-	key.Write(id)
-	return bkt.Put(key.Bytes(), nil)
+	return tx.put(bkt, cloneBytes(key), nil)
 }
 
-func (i index) delete(bkt *bolt.Bucket, rv reflect.Value, id []byte) error {
+// changed returns true if any field of the index is encoded differently for
+// the structs a and b.
+func (i index) changed(a, b reflect.Value) (bool, error) {
+	for _, field := range i.Fields {
+		ka, err := field.append(nil, a.Field(field.StructPos).Interface())
+		if err != nil {
+			return false, err
+		}
+		kb, err := field.append(nil, b.Field(field.StructPos).Interface())
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(ka, kb) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (i index) delete(tx *Tx, bkt *bolt.Bucket, rv reflect.Value, id []byte) error {
 	bkt = bkt.Bucket(i.FullName)
-	key := &bytes.Buffer{}
+	buf := getKeyBuf()
+	defer putKeyBuf(buf)
+	key := (*buf)[:0]
 	for n, field := range i.Fields {
-		b, err := bytesort.Encode(rv.Field(field.StructPos).Interface())
+		var err error
+		key, err = field.append(key, rv.Field(field.StructPos).Interface())
 		if err != nil {
 			return err
 		}
-		key.Write(b)
 		if field.Type.Kind() == reflect.String && n < len(i.Fields)-1 {
-			bkt = bkt.Bucket(key.Bytes())
+			bkt = bkt.Bucket(key)
 			if bkt == nil {
 				// odd, the index is out of sync. still fulfills the delete though.
 				return nil
 			}
-			key.Reset()
+			key = key[:0]
 		}
 	}
 	// TODO Delete empty buckets
-	if i.Unique {
-		// Key -> value (value being the primary ID)
-		return bkt.Delete(key.Bytes())
+	if !i.Unique {
+		key = append(key, id...)
 	}
-	key.Write(id)
-	return bkt.Delete(key.Bytes())
+	*buf = key
+	return tx.delete(bkt, key)
+}
+
+// keyPool holds reusable buffers for building index keys.
+var keyPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 64)
+		return &b
+	},
+}
+
+func getKeyBuf() *[]byte {
+	return keyPool.Get().(*[]byte)
+}
+
+func putKeyBuf(b *[]byte) {
+	keyPool.Put(b)
+}
+
+func cloneBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
 }
 
 type indexField struct {
 	StructPos int
 	reflect.StructField
+	Collation bytesort.Collation
+}
+
+func newIndexField(t reflect.Type, stl structTagList, pos int) (indexField, error) {
+	f := indexField{StructPos: pos, StructField: t.Field(pos)}
+	f.Collation = stl.collation(pos)
+	if f.Collation != 0 && f.Type.Kind() != reflect.String {
+		return f, fmt.Errorf("collation %q of field %q requires a string, got %s", f.Collation, f.Name, f.Type.Kind())
+	}
+	return f, nil
+}
+
+// fixedLength returns true if all values of the field are encoded with the
+// same length. Strings, Decimals and numbers of arbitrary precision vary.
+func (f indexField) fixedLength() bool {
+	k := f.Type.Kind()
+	return k != reflect.String && k != reflect.Ptr
+}
+
+// append encodes v and appends it to dst, applying the field's collation.
+func (f indexField) append(dst []byte, v interface{}) ([]byte, error) {
+	if f.Collation != 0 {
+		return f.Collation.Append(dst, v)
+	}
+	return bytesort.Append(dst, v)
 }
@@ -0,0 +1,208 @@
+package bolster
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nochso/bolster/errlist"
+)
+
+const (
+	tagRequired = "required"
+	tagMin      = "min"
+	tagMax      = "max"
+	tagRegex    = "regex"
+	tagEnum     = "enum"
+)
+
+// Validator is implemented by structs that validate their own values.
+//
+// Validate is called when inserting, updating or upserting an item after its
+// field rules have been checked.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError occurs when a field violates a rule of its struct tag.
+type FieldError struct {
+	Field string
+	Rule  string
+	Err   error
+}
+
+// Error implements the built-in error interface.
+func (e FieldError) Error() string {
+	return fmt.Sprintf("field %q violates rule %q: %s", e.Field, e.Rule, e.Err)
+}
+
+// Unwrap returns the inner error.
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldRule checks a value of a single struct field.
+type fieldRule struct {
+	StructPos int
+	Name      string // name of the field
+	Rule      string // tag of the rule
+	check     func(reflect.Value) error
+}
+
+// newFieldRules returns the validation rules of all fields.
+//
+// Rules are tags consisting of the rule's name and its arguments:
+//
+//	required      value must not be the zero value
+//	min <n>       minimum length of strings, slices and maps or minimum number
+//	max <n>       maximum length of strings, slices and maps or maximum number
+//	regex <expr>  string must match the regular expression
+//	enum <a b c>  value must be one of the space separated words
+//
+// Lengths of strings are counted in runes. Regular expressions must not
+// contain commas as they separate tags.
+func newFieldRules(t reflect.Type, stl structTagList) ([]fieldRule, error) {
+	var rules []fieldRule
+	for pos, tags := range stl {
+		f := t.Field(pos)
+		for _, tag := range tags {
+			words := strings.Fields(tag)
+			if len(words) == 0 {
+				continue
+			}
+			var check func(reflect.Value) error
+			var err error
+			switch words[0] {
+			case tagRequired:
+				check, err = newRequiredRule(words)
+			case tagMin, tagMax:
+				check, err = newLimitRule(f.Type, words)
+			case tagRegex:
+				check, err = newRegexRule(f.Type, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), tagRegex)))
+			case tagEnum:
+				check, err = newEnumRule(words)
+			default:
+				continue
+			}
+			if err == nil && f.PkgPath != "" {
+				err = errors.New("field must be exported")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid rule %q of field %q: %s", tag, f.Name, err)
+			}
+			rules = append(rules, fieldRule{StructPos: pos, Name: f.Name, Rule: strings.TrimSpace(tag), check: check})
+		}
+	}
+	return rules, nil
+}
+
+func newRequiredRule(words []string) (func(reflect.Value) error, error) {
+	if len(words) != 1 {
+		return nil, errors.New("expected no arguments")
+	}
+	return func(rv reflect.Value) error {
+		if rv.IsZero() {
+			return errors.New("value is required")
+		}
+		return nil
+	}, nil
+}
+
+func newLimitRule(t reflect.Type, words []string) (func(reflect.Value) error, error) {
+	if len(words) != 2 {
+		return nil, errors.New("expected a single number")
+	}
+	limit, err := strconv.ParseFloat(words[1], 64)
+	if err != nil {
+		return nil, err
+	}
+	isMin := words[0] == tagMin
+	compare := func(n float64, what string) error {
+		if isMin && n < limit {
+			return fmt.Errorf("%s %v is less than %v", what, n, limit)
+		}
+		if !isMin && n > limit {
+			return fmt.Errorf("%s %v is greater than %v", what, n, limit)
+		}
+		return nil
+	}
+	switch k := t.Kind(); {
+	case k == reflect.String:
+		return func(rv reflect.Value) error {
+			return compare(float64(utf8.RuneCountInString(rv.String())), "length")
+		}, nil
+	case k == reflect.Slice || k == reflect.Map || k == reflect.Array:
+		return func(rv reflect.Value) error {
+			return compare(float64(rv.Len()), "length")
+		}, nil
+	case k >= reflect.Int && k <= reflect.Int64:
+		return func(rv reflect.Value) error {
+			return compare(float64(rv.Int()), "value")
+		}, nil
+	case k >= reflect.Uint && k <= reflect.Uint64:
+		return func(rv reflect.Value) error {
+			return compare(float64(rv.Uint()), "value")
+		}, nil
+	case k == reflect.Float32 || k == reflect.Float64:
+		return func(rv reflect.Value) error {
+			return compare(rv.Float(), "value")
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func newRegexRule(t reflect.Type, expr string) (func(reflect.Value) error, error) {
+	if t.Kind() != reflect.String {
+		return nil, fmt.Errorf("expected string field, got %s", t)
+	}
+	if expr == "" {
+		return nil, errors.New("expected a regular expression")
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(rv reflect.Value) error {
+		if !re.MatchString(rv.String()) {
+			return fmt.Errorf("value %q does not match %q", rv.String(), expr)
+		}
+		return nil
+	}, nil
+}
+
+func newEnumRule(words []string) (func(reflect.Value) error, error) {
+	if len(words) < 2 {
+		return nil, errors.New("expected at least one value")
+	}
+	allowed := words[1:]
+	return func(rv reflect.Value) error {
+		s := fmt.Sprint(rv.Interface())
+		for _, a := range allowed {
+			if s == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("value %q is not one of %q", s, allowed)
+	}, nil
+}
+
+// validate checks all field rules and the Validator of v.
+//
+// All violations are returned at once.
+func (st structType) validate(rv reflect.Value, v interface{}) error {
+	errs := errlist.New()
+	for _, r := range st.Rules {
+		err := r.check(rv.Field(r.StructPos))
+		if err != nil {
+			errs.Append(FieldError{Field: r.Name, Rule: r.Rule, Err: err})
+		}
+	}
+	if val, ok := v.(Validator); ok {
+		errs.Append(val.Validate())
+	}
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,130 @@
+package bolster_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithRules struct {
+	ID     int      `bolster:"inc"`
+	Name   string   `bolster:"required,min 2,max 5"`
+	Email  string   `bolster:"regex ^[^@ ]+@[^@ ]+$"`
+	Status string   `bolster:"enum new active closed"`
+	Age    int      `bolster:"min 0,max 150"`
+	Tags   []string `bolster:"max 2"`
+}
+
+type structWithValidator struct {
+	ID    int
+	Start int
+	End   int
+}
+
+func (s *structWithValidator) Validate() error {
+	if s.End < s.Start {
+		return errors.New("end must not be before start")
+	}
+	return nil
+}
+
+func TestTx_Insert_validation(t *testing.T) {
+	tests := map[string]struct {
+		item       *structWithRules
+		violations []string // names of fields violating rules
+	}{
+		"valid": {
+			&structWithRules{Name: "foo", Email: "foo@example.com", Status: "new", Age: 30, Tags: []string{"a"}},
+			nil,
+		},
+		"required": {
+			&structWithRules{Email: "a@b", Status: "new"},
+			[]string{"Name", "Name"},
+		},
+		"length": {
+			&structWithRules{Name: "bärbel", Email: "a@b", Status: "new", Tags: []string{"a", "b", "c"}},
+			[]string{"Name", "Tags"},
+		},
+		"number": {
+			&structWithRules{Name: "foo", Email: "a@b", Status: "new", Age: -1},
+			[]string{"Age"},
+		},
+		"all": {
+			&structWithRules{Name: "f", Email: "foo", Status: "old", Age: 200},
+			[]string{"Name", "Email", "Status", "Age"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			err := st.Register(structWithRules{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := internal.DumpStore(st)
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.Insert(test.item)
+			})
+			if len(test.violations) == 0 {
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			t.Log(err)
+			var txErr bolster.Error
+			if !errors.As(err, &txErr) {
+				t.Fatalf("expected bolster.Error, got %T", err)
+			}
+			var fields []string
+			if fe, ok := txErr.Err.(bolster.FieldError); ok {
+				fields = append(fields, fe.Field)
+			} else {
+				for _, err := range txErr.Err.(interface{ Unwrap() []error }).Unwrap() {
+					fields = append(fields, err.(bolster.FieldError).Field)
+				}
+			}
+			if len(fields) != len(test.violations) {
+				t.Fatalf("expected violations of %v, got %v", test.violations, fields)
+			}
+			for i := range fields {
+				if fields[i] != test.violations[i] {
+					t.Errorf("expected violations of %v, got %v", test.violations, fields)
+					break
+				}
+			}
+			assertSameDump(t, exp, internal.DumpStore(st))
+		})
+	}
+}
+
+func TestTx_Update_validator(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithValidator{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &structWithValidator{ID: 1, Start: 1, End: 2}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item.End = 0
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Update(item)
+	})
+	if err == nil {
+		t.Error("expected error, got nil")
+	} else {
+		t.Log(err)
+	}
+}
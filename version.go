@@ -0,0 +1,69 @@
+package bolster
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+const tagVersion = "version"
+
+// newVersionField returns the position of the field tagged
+// `bolster:"version"` or -1 if there is none.
+func newVersionField(t reflect.Type, stl structTagList) (int, error) {
+	keys := stl.filter(tagVersion)
+	if len(keys) > 1 {
+		return -1, fmt.Errorf("must not have multiple fields with tag %q", tagVersion)
+	}
+	if len(keys) == 0 {
+		return -1, nil
+	}
+	f := t.Field(keys[0])
+	if k := f.Type.Kind(); k < reflect.Int || k > reflect.Uint64 {
+		return -1, fmt.Errorf("version field %q must be integer, got %s", f.Name, k)
+	}
+	return keys[0], nil
+}
+
+// setVersion checks and increments the version field of rv.
+//
+// old is the stored item or an invalid value if the item is new. New items
+// start at version 1. Existing items must have the same version as the stored
+// item, otherwise ErrVersionConflict is returned.
+// setsFieldsOnUpdate returns true if updating an item changes fields of the
+// struct, which requires a pointer to it.
+func (st structType) setsFieldsOnUpdate() bool {
+	return st.Version != -1 || st.Timestamps.Created != -1 || st.Timestamps.Updated != -1
+}
+
+func (tx *Tx) setVersion(st structType, rv, old reflect.Value) error {
+	if st.Version == -1 {
+		return nil
+	}
+	f := rv.Field(st.Version)
+	if !old.IsValid() {
+		tx.setField(f, reflect.ValueOf(1).Convert(f.Type()))
+		return nil
+	}
+	next := reflect.New(f.Type()).Elem()
+	stored := old.Field(st.Version)
+	if k := f.Kind(); k >= reflect.Int && k <= reflect.Int64 {
+		if f.Int() != stored.Int() {
+			return fmt.Errorf("%w: expected version %d, got %d", ErrVersionConflict, stored.Int(), f.Int())
+		}
+		if stored.Int() == math.MaxInt64 || f.OverflowInt(stored.Int()+1) {
+			return fmt.Errorf("next version overflows field of type %s", f.Type())
+		}
+		next.SetInt(stored.Int() + 1)
+	} else {
+		if f.Uint() != stored.Uint() {
+			return fmt.Errorf("%w: expected version %d, got %d", ErrVersionConflict, stored.Uint(), f.Uint())
+		}
+		if stored.Uint() == math.MaxUint64 || f.OverflowUint(stored.Uint()+1) {
+			return fmt.Errorf("next version overflows field of type %s", f.Type())
+		}
+		next.SetUint(stored.Uint() + 1)
+	}
+	tx.setField(f, next)
+	return nil
+}
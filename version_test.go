@@ -0,0 +1,113 @@
+package bolster_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithVersion struct {
+	ID      int
+	Name    string
+	Version uint8 `bolster:"version"`
+}
+
+func TestTx_Update_version(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithVersion{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &structWithVersion{ID: 1, Name: "foo", Version: 5}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Insert(item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Version != 1 {
+		t.Errorf("expected version 1 after insert, got %d", item.Version)
+	}
+
+	// two users read the same version
+	alice, bob := &structWithVersion{}, &structWithVersion{}
+	err = st.Read(func(tx *bolster.Tx) error {
+		tx.Get(alice, 1)
+		return tx.Get(bob, 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.Name = "alice"
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Update(alice)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Version != 2 {
+		t.Errorf("expected version 2 after update, got %d", alice.Version)
+	}
+
+	bob.Name = "bob"
+	for name, action := range map[string]func(*bolster.Tx, interface{}) error{
+		"update": (*bolster.Tx).Update,
+		"upsert": (*bolster.Tx).Upsert,
+	} {
+		err = st.Write(func(tx *bolster.Tx) error {
+			return action(tx, bob)
+		})
+		if e, ok := err.(bolster.Error); !ok || !e.IsVersionConflict() || !errors.Is(err, bolster.ErrVersionConflict) {
+			t.Errorf("%s: expected ErrVersionConflict, got %v", name, err)
+		} else {
+			t.Log(err)
+		}
+		if bob.Version != 1 {
+			t.Errorf("%s: expected version to be unchanged, got %d", name, bob.Version)
+		}
+	}
+
+	err = st.Read(func(tx *bolster.Tx) error {
+		stored := &structWithVersion{}
+		err := tx.Get(stored, 1)
+		if stored.Name != "alice" || stored.Version != 2 {
+			t.Errorf("expected update of alice to be kept, got %+v", stored)
+		}
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTx_Update_versionOverflow(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithVersion{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &structWithVersion{ID: 1}
+	err = st.Write(func(tx *bolster.Tx) error {
+		err := tx.Insert(item)
+		for err == nil && item.Version < math.MaxUint8 {
+			err = tx.Update(item)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Write(func(tx *bolster.Tx) error {
+		return tx.Update(item)
+	})
+	if err == nil {
+		t.Error("expected error, got nil")
+	} else {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,219 @@
+package bolster
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ChangeType is the kind of action that changed an item.
+type ChangeType int
+
+// Kinds of changes delivered by Store.Watch.
+//
+// ChangeInsert to ChangeUpsert have the same order as the actions of Tx.
+const (
+	ChangeInsert ChangeType = iota
+	ChangeUpdate
+	ChangeUpsert
+	ChangeDelete
+	ChangeTruncate
+)
+
+var changeTypeNames = [...]string{"insert", "update", "upsert", "delete", "truncate"}
+
+func (c ChangeType) String() string {
+	if c < 0 || int(c) >= len(changeTypeNames) {
+		return fmt.Sprintf("ChangeType(%d)", c)
+	}
+	return changeTypeNames[c]
+}
+
+// Change describes a committed change of an item.
+//
+// Old and New are pointers to copies of the stored item before and after the
+// change. Old is nil for inserts and for upserts of new items. New is nil for
+// deletes. Both are nil for truncates, which affect all items of a type.
+type Change struct {
+	Type ChangeType
+	Old  interface{}
+	New  interface{}
+}
+
+// SlowConsumerPolicy decides what happens when a watcher's buffer is full.
+type SlowConsumerPolicy int
+
+const (
+	// Disconnect closes the channel of a watcher that can not keep up.
+	// Consumers can tell that they missed changes.
+	Disconnect SlowConsumerPolicy = iota
+	// DropNewest discards changes that do not fit into the buffer.
+	DropNewest
+)
+
+// DefaultWatchBuffer is the amount of changes buffered for each watcher
+// unless WatchOptions.Buffer is set.
+const DefaultWatchBuffer = 64
+
+// WatchOptions configure a watcher.
+type WatchOptions struct {
+	// Filter returns true for changes that should be delivered.
+	// All changes are delivered when Filter is nil.
+	Filter func(Change) bool
+	// Buffer is the capacity of the watcher's channel.
+	// DefaultWatchBuffer is used when it is zero or less.
+	Buffer int
+	// Policy applies when the buffer is full.
+	Policy SlowConsumerPolicy
+}
+
+type watcher struct {
+	st   structType
+	ch   chan Change
+	opts WatchOptions
+}
+
+// Watch returns a channel receiving changes to items of v's type.
+//
+// Changes are sent after a read-write transaction has been committed
+// successfully, in the order they were made. Changes of rolled back
+// transactions and failed savepoints are never sent. Only changes for which
+// filter returns true are sent; filter may be nil.
+//
+// cancel stops watching and closes the channel. Slow consumers are
+// disconnected, see WatchWith for other options.
+func (s *Store) Watch(v interface{}, filter func(Change) bool) (<-chan Change, func(), error) {
+	return s.WatchWith(v, WatchOptions{Filter: filter})
+}
+
+// WatchWith is like Watch with additional options.
+//
+// Filters are called while committing and must not use the store.
+func (s *Store) WatchWith(v interface{}, opts WatchOptions) (<-chan Change, func(), error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	st, ok := s.types[t]
+	if !ok {
+		return nil, nil, fmt.Errorf("unregistered struct: %v", t)
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultWatchBuffer
+	}
+	w := &watcher{st: st, ch: make(chan Change, opts.Buffer), opts: opts}
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if s.closed {
+		return nil, nil, errors.New("store is closed")
+	}
+	s.watchers = append(s.watchers, w)
+	return w.ch, func() { s.unwatch(w) }, nil
+}
+
+// unwatch removes a watcher and closes its channel.
+// It is safe to call multiple times.
+func (s *Store) unwatch(w *watcher) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	s.removeWatcher(w)
+}
+
+// removeWatcher must be called with watchMu locked.
+func (s *Store) removeWatcher(w *watcher) {
+	for i, ww := range s.watchers {
+		if ww == w {
+			s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+			close(w.ch)
+			return
+		}
+	}
+}
+
+// closeWatchers closes the channels of all watchers.
+func (s *Store) closeWatchers() {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for _, w := range s.watchers {
+		close(w.ch)
+	}
+	s.watchers = nil
+	s.closed = true
+}
+
+// isWatched returns true if any watcher is interested in st.
+func (s *Store) isWatched(st structType) bool {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for _, w := range s.watchers {
+		if w.st.Type == st.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// publish sends committed changes to the watchers of their types.
+func (s *Store) publish(changes []typedChange) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	for _, c := range changes {
+		// watchers may be removed while sending
+		watchers := append([]*watcher(nil), s.watchers...)
+		for _, w := range watchers {
+			if w.st.Type != c.st.Type {
+				continue
+			}
+			if w.opts.Filter != nil && !w.opts.Filter(c.Change) {
+				continue
+			}
+			select {
+			case w.ch <- c.Change:
+			default:
+				if w.opts.Policy == Disconnect {
+					s.removeWatcher(w)
+				}
+			}
+		}
+	}
+}
+
+type typedChange struct {
+	st structType
+	Change
+}
+
+// recordChange writes a change to the changelog and remembers it for
+// watchers until the transaction is committed.
+//
+// old and item are struct values; invalid values are left out of the change.
+func (tx *Tx) recordChange(st structType, ct ChangeType, old, item reflect.Value) error {
+	err := tx.logChange(st, ct, old, item)
+	if err != nil {
+		return err
+	}
+	if !tx.store.isWatched(st) {
+		return nil
+	}
+	c := typedChange{st: st, Change: Change{Type: ct}}
+	if old.IsValid() {
+		c.Old = copyStruct(old)
+	}
+	if item.IsValid() {
+		c.New = copyStruct(item)
+	}
+	if tx.changes == nil {
+		tx.btx.OnCommit(func() {
+			tx.store.publish(tx.changes)
+		})
+	}
+	tx.changes = append(tx.changes, c)
+	return nil
+}
+
+// copyStruct returns a pointer to a shallow copy of rv.
+func copyStruct(rv reflect.Value) interface{} {
+	c := reflect.New(rv.Type())
+	c.Elem().Set(rv)
+	return c.Interface()
+}
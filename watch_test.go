@@ -0,0 +1,197 @@
+package bolster_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+// receive returns all changes available without blocking.
+func receive(ch <-chan bolster.Change) []bolster.Change {
+	var changes []bolster.Change
+	for {
+		select {
+		case c, ok := <-ch:
+			if !ok {
+				return changes
+			}
+			changes = append(changes, c)
+		default:
+			return changes
+		}
+	}
+}
+
+// slowConsumer returns a test of a watcher with a buffer for one change
+// receiving two changes.
+func slowConsumer(policy bolster.SlowConsumerPolicy, count int, closed bool) func(*testing.T, *bolster.Store) {
+	return func(t *testing.T, st *bolster.Store) {
+		ch, cancel, err := st.WatchWith(structWithIDAndField{}, bolster.WatchOptions{Buffer: 1, Policy: policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = st.Write(func(tx *bolster.Tx) error {
+			tx.Insert(&structWithIDAndField{ID: 1})
+			return tx.Insert(&structWithIDAndField{ID: 2})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, isClosed := 0, false
+		for i := 0; i < 2; i++ {
+			select {
+			case _, ok := <-ch:
+				if ok {
+					n++
+				} else {
+					isClosed = true
+				}
+			default:
+			}
+		}
+		if n != count || isClosed != closed {
+			t.Errorf("expected %d changes and closed=%v, got %d and %v", count, closed, n, isClosed)
+		}
+		cancel()
+		cancel() // cancelling again is a no-op
+	}
+}
+
+// TestStore_Watch runs each test on a fresh store with the watched types
+// registered.
+func TestStore_Watch(t *testing.T) {
+	tests := map[string]func(t *testing.T, st *bolster.Store){
+		"changes": func(t *testing.T, st *bolster.Store) {
+			ch, cancel, err := st.Watch(structWithIDAndField{}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cancel()
+			err = st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithIDAndField{ID: 1, Name: "foo"})
+				tx.Update(&structWithIDAndField{ID: 1, Name: "bar"})
+				tx.Upsert(&structWithIDAndField{ID: 2, Name: "baz"})
+				tx.Delete(&structWithIDAndField{ID: 1})
+				tx.Insert(&structWithAutoincrement{}) // not watched
+				return tx.Truncate(structWithIDAndField{})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes := receive(ch)
+			exp := []struct {
+				typ      bolster.ChangeType
+				old, new string
+			}{
+				{bolster.ChangeInsert, "", "foo"},
+				{bolster.ChangeUpdate, "foo", "bar"},
+				{bolster.ChangeUpsert, "", "baz"},
+				{bolster.ChangeDelete, "bar", ""},
+				{bolster.ChangeTruncate, "", ""},
+			}
+			if len(changes) != len(exp) {
+				t.Fatalf("expected %d changes, got %d: %v", len(exp), len(changes), changes)
+			}
+			name := func(v interface{}) string {
+				if v == nil {
+					return ""
+				}
+				return v.(*structWithIDAndField).Name
+			}
+			for i, c := range changes {
+				if c.Type != exp[i].typ || name(c.Old) != exp[i].old || name(c.New) != exp[i].new {
+					t.Errorf("#%d: expected %v %q -> %q, got %v %q -> %q", i, exp[i].typ, exp[i].old, exp[i].new, c.Type, name(c.Old), name(c.New))
+				}
+			}
+		},
+		"rollback": func(t *testing.T, st *bolster.Store) {
+			ch, cancel, err := st.Watch(&structWithIDAndField{}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cancel()
+			st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithIDAndField{ID: 1, Name: "foo"})
+				return errors.New("failure")
+			})
+			tx, err := st.Begin(true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx.Insert(&structWithIDAndField{ID: 1, Name: "foo"})
+			tx.Rollback()
+			err = st.Write(func(tx *bolster.Tx) error {
+				tx.Savepoint(func(tx *bolster.Tx) error {
+					tx.Insert(&structWithIDAndField{ID: 1, Name: "foo"})
+					return errors.New("failure")
+				})
+				return tx.Insert(&structWithIDAndField{ID: 2, Name: "bar"})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes := receive(ch)
+			if len(changes) != 1 || changes[0].New.(*structWithIDAndField).Name != "bar" {
+				t.Errorf("expected only the committed change, got %v", changes)
+			}
+		},
+		"filter": func(t *testing.T, st *bolster.Store) {
+			ch, cancel, err := st.Watch(structWithIDAndField{}, func(c bolster.Change) bool {
+				return c.Type == bolster.ChangeDelete
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cancel()
+			err = st.Write(func(tx *bolster.Tx) error {
+				tx.Insert(&structWithIDAndField{ID: 1})
+				return tx.Delete(&structWithIDAndField{ID: 1})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes := receive(ch)
+			if len(changes) != 1 || changes[0].Type != bolster.ChangeDelete {
+				t.Errorf("expected a single delete, got %v", changes)
+			}
+		},
+		"slowConsumerDisconnect": slowConsumer(bolster.Disconnect, 1, true),
+		"slowConsumerDropNewest": slowConsumer(bolster.DropNewest, 1, false),
+		"close": func(t *testing.T, st *bolster.Store) {
+			ch, _, err := st.Watch(structWithIDAndField{}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st.Close()
+			if _, ok := <-ch; ok {
+				t.Error("expected channel to be closed")
+			}
+			_, _, err = st.Watch(structWithIDAndField{}, nil)
+			if err == nil {
+				t.Error("expected error when watching a closed store, got nil")
+			}
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			err := st.Register(structWithIDAndField{}, structWithAutoincrement{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st)
+		})
+	}
+}
+
+func TestStore_Watch_unregistered(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	_, _, err := st.Watch(structWithIDAndField{}, nil)
+	if err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -0,0 +1,211 @@
+package bolster
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+type condOp int
+
+const (
+	opEq condOp = iota
+	opLt
+	opLe
+	opGt
+	opGe
+)
+
+var condOpNames = [...]string{"=", "<", "<=", ">", ">="}
+
+// Cond is a condition on an indexed field used by DeleteWhere and
+// UpdateWhere.
+//
+// The field must be the only field of an index. Values are compared in the
+// order of the index, e.g. applying its collation.
+type Cond struct {
+	field string
+	op    condOp
+	value interface{}
+}
+
+// Eq returns a condition matching items whose field equals v.
+func Eq(field string, v interface{}) Cond { return Cond{field, opEq, v} }
+
+// Lt returns a condition matching items whose field is less than v.
+func Lt(field string, v interface{}) Cond { return Cond{field, opLt, v} }
+
+// Le returns a condition matching items whose field is less than or equal to v.
+func Le(field string, v interface{}) Cond { return Cond{field, opLe, v} }
+
+// Gt returns a condition matching items whose field is greater than v.
+func Gt(field string, v interface{}) Cond { return Cond{field, opGt, v} }
+
+// Ge returns a condition matching items whose field is greater than or equal to v.
+func Ge(field string, v interface{}) Cond { return Cond{field, opGe, v} }
+
+func (c Cond) String() string {
+	return fmt.Sprintf("%s %s %v", c.field, condOpNames[c.op], c.value)
+}
+
+// match returns true if the encoded value cmp compares to the encoded value of
+// the condition as required.
+func (c Cond) match(cmp int) bool {
+	switch c.op {
+	case opEq:
+		return cmp == 0
+	case opLt:
+		return cmp < 0
+	case opLe:
+		return cmp <= 0
+	case opGt:
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// DeleteWhere deletes all items of v's type matching c and returns the amount
+// of deleted items.
+//
+// Each item is deleted like calling Delete with it. Expired and soft deleted
+// items do not match.
+func (tx *Tx) DeleteWhere(v interface{}, c Cond) (int, error) {
+	return tx.applyWhere(v, c, delete, nil)
+}
+
+// UpdateWhere updates all items of v's type matching c and returns the amount
+// of updated items.
+//
+// v must be a pointer to a struct. Each item is decoded into v before fn is
+// called to modify it. The item is then saved like calling Update with v, i.e.
+// all indexes are kept up to date. fn must not change the ID. Expired and
+// soft deleted items do not match.
+//
+// Matching items are looked up before the first call of fn, so changes made
+// by fn do not affect which items are updated. An error returned by fn stops
+// the update and fails the transaction.
+func (tx *Tx) UpdateWhere(v interface{}, c Cond, fn func() error) (int, error) {
+	return tx.applyWhere(v, c, update, fn)
+}
+
+func (tx *Tx) applyWhere(v interface{}, c Cond, action txAction, fn func() error) (int, error) {
+	st, rv, err := tx.validateStruct(v, action)
+	if err == nil && action == update && !rv.CanAddr() {
+		// matching items are decoded into v
+		err = fmt.Errorf("expected pointer to struct, got %v", rv.Kind())
+	}
+	if tx.errs.HasError() {
+		return 0, tx.addErr(ErrBadTransaction)
+	}
+	if err != nil {
+		return 0, tx.addErr(err)
+	}
+	ids, err := tx.findIDs(st, c)
+	if err != nil {
+		return 0, tx.addErr(err)
+	}
+	n := 0
+	bktData := tx.dataBkt(st)
+	for _, id := range ids {
+		err = tx.ctx.Err()
+		if err != nil {
+			return n, tx.addErr(err)
+		}
+		b := bktData.Get(id)
+		if b == nil {
+			continue
+		}
+		item, err := tx.decode(st, b)
+		if err != nil {
+			return n, tx.addErr(err)
+		}
+		if st.isHidden(item, tx.store.now) {
+			continue
+		}
+		if action == delete {
+			err = tx.remove(st, item, item.Addr().Interface(), true)
+		} else {
+			err = tx.updateWith(st, rv, v, item, fn)
+		}
+		if err != nil {
+			return n, tx.addErr(err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// updateWith decodes item into v, lets fn modify it and updates it.
+func (tx *Tx) updateWith(st structType, rv reflect.Value, v interface{}, item reflect.Value, fn func() error) error {
+	tx.setField(rv, item)
+	err := fn()
+	if err != nil {
+		return err
+	}
+	if !sameID(st, item, rv) {
+		return fmt.Errorf("ID of item %s must not be changed, got %s", st.ID.formatStruct(item), st.ID.formatStruct(rv))
+	}
+	return tx.save(st, rv, v, update)
+}
+
+// findIDs returns the encoded IDs of all items matching c, in order of the
+// index.
+func (tx *Tx) findIDs(st structType, c Cond) ([][]byte, error) {
+	idx, ok := st.fieldIndex(c.field)
+	if !ok {
+		return nil, fmt.Errorf("condition %s: field must be the only field of an index", c)
+	}
+	f := idx.Fields[0]
+	if c.value == nil {
+		return nil, fmt.Errorf("condition %s: invalid value", c)
+	}
+	if act := reflect.TypeOf(c.value); act != f.Type {
+		return nil, fmt.Errorf("condition %s: incompatible type of value: expected %v, got %v", c, f.Type, act)
+	}
+	bound, err := f.append(nil, c.value)
+	if err != nil {
+		return nil, fmt.Errorf("condition %s: %w", c, err)
+	}
+	// Keys of non-unique indexes are the value followed by the ID. They are
+	// only sorted by value if all values have the same length, e.g. "a"+ID
+	// may sort after "ab"+ID.
+	sorted := idx.Unique || f.fixedLength()
+	var ids [][]byte
+	cur := tx.idxBkt(st).Bucket(idx.FullName).Cursor()
+	k, id := cur.First()
+	if c.op == opEq || c.op == opGt || c.op == opGe {
+		// keys of values greater than or equal to bound never sort before it
+		k, id = cur.Seek(bound)
+	}
+	for ; k != nil; k, id = cur.Next() {
+		if c.op == opEq && !bytes.HasPrefix(k, bound) {
+			// keys of equal values start with bound
+			break
+		}
+		value := k
+		if !idx.Unique {
+			// non-unique index keys end with the 8 byte ID of the item
+			value, id = k[:len(k)-8], k[len(k)-8:]
+		}
+		cmp := bytes.Compare(value, bound)
+		if c.match(cmp) {
+			ids = append(ids, cloneBytes(id))
+		} else if sorted && c.op != opGt {
+			// keys are sorted, no further key can match
+			break
+		}
+	}
+	return ids, nil
+}
+
+// fieldIndex returns the first index consisting only of the field with the
+// given name.
+func (st structType) fieldIndex(name string) (index, bool) {
+	for _, idx := range st.Indexes {
+		if len(idx.Fields) == 1 && idx.Fields[0].Name == name {
+			return idx, true
+		}
+	}
+	return index{}, false
+}
@@ -0,0 +1,246 @@
+package bolster_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nochso/bolster"
+	"github.com/nochso/bolster/internal"
+)
+
+type structWithWhere struct {
+	ID     int    `bolster:"inc"`
+	UserID int    `bolster:"index"`
+	Status string `bolster:"index"`
+	Note   string
+}
+
+// TestTx_where runs each test on a fresh store holding five items.
+func TestTx_where(t *testing.T) {
+	tests := map[string]func(t *testing.T, st *bolster.Store){
+		"DeleteWhere": func(t *testing.T, st *bolster.Store) {
+			var n int
+			err := st.Write(func(tx *bolster.Tx) error {
+				var err error
+				n, err = tx.DeleteWhere(&structWithWhere{}, bolster.Eq("UserID", 1))
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 2 {
+				t.Errorf("expected 2 deleted items, got %d", n)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+		"DeleteWhereConds": func(t *testing.T, st *bolster.Store) {
+			tests := []struct {
+				cond bolster.Cond
+				exp  int
+			}{
+				{bolster.Eq("UserID", 0), 0},
+				{bolster.Eq("UserID", 2), 1},
+				{bolster.Lt("UserID", 2), 2},
+				{bolster.Le("UserID", 2), 3},
+				{bolster.Gt("UserID", 2), 2},
+				{bolster.Ge("UserID", 2), 3},
+				{bolster.Gt("UserID", 4), 0},
+				{bolster.Eq("Status", "done"), 2},
+				{bolster.Ge("Status", "e"), 3},
+			}
+			errRollback := errors.New("rollback")
+			for _, test := range tests {
+				t.Run(test.cond.String(), func(t *testing.T) {
+					// rolled back so that every condition sees all items
+					err := st.Write(func(tx *bolster.Tx) error {
+						n, err := tx.DeleteWhere(structWithWhere{}, test.cond)
+						if err != nil {
+							return err
+						}
+						if n != test.exp {
+							t.Errorf("expected %d deleted items, got %d", test.exp, n)
+						}
+						count := 0
+						err = tx.Each(&structWithWhere{}, func() error {
+							count++
+							return nil
+						})
+						if count != 5-test.exp {
+							t.Errorf("expected %d remaining items, got %d", 5-test.exp, count)
+						}
+						if err != nil {
+							return err
+						}
+						return errRollback
+					})
+					if err != errRollback {
+						t.Fatal(err)
+					}
+				})
+			}
+		},
+		"UpdateWhere": func(t *testing.T, st *bolster.Store) {
+			var n int
+			err := st.Write(func(tx *bolster.Tx) error {
+				item := &structWithWhere{}
+				var err error
+				n, err = tx.UpdateWhere(item, bolster.Lt("UserID", 3), func() error {
+					item.Status = "archived"
+					return nil
+				})
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 3 {
+				t.Errorf("expected 3 updated items, got %d", n)
+			}
+			internal.GoldStore(t, st, *updateGold)
+		},
+		"UpdateWhereErrors": func(t *testing.T, st *bolster.Store) {
+			exp := internal.DumpStore(st)
+			errFn := errors.New("fn failed")
+			tests := map[string]struct {
+				cond bolster.Cond
+				fn   func(item *structWithWhere) error
+			}{
+				"unindexed field": {bolster.Eq("Note", ""), nil},
+				"unknown field":   {bolster.Eq("Foo", 1), nil},
+				"integer ID":      {bolster.Eq("ID", 1), nil},
+				"value type":      {bolster.Eq("UserID", "1"), nil},
+				"nil value":       {bolster.Eq("UserID", nil), nil},
+				"fn error": {bolster.Eq("UserID", 1), func(item *structWithWhere) error {
+					item.Status = "archived"
+					return errFn
+				}},
+				"changed ID": {bolster.Eq("UserID", 1), func(item *structWithWhere) error {
+					item.ID++
+					return nil
+				}},
+			}
+			for name, test := range tests {
+				t.Run(name, func(t *testing.T) {
+					err := st.Write(func(tx *bolster.Tx) error {
+						item := &structWithWhere{}
+						_, err := tx.UpdateWhere(item, test.cond, func() error {
+							return test.fn(item)
+						})
+						return err
+					})
+					t.Log(err)
+					if err == nil {
+						t.Fatal("expected error, got nil")
+					}
+					if name == "fn error" && !errors.Is(err, errFn) {
+						t.Errorf("expected error of fn, got %v", err)
+					}
+					assertSameDump(t, exp, internal.DumpStore(st))
+				})
+			}
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			err := st.Register(structWithWhere{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = st.Write(func(tx *bolster.Tx) error {
+				return tx.InsertMany([]structWithWhere{
+					{UserID: 1, Status: "new"},
+					{UserID: 2, Status: "new"},
+					{UserID: 1, Status: "done"},
+					{UserID: 3, Status: "done"},
+					{UserID: 4, Status: "new"},
+				})
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, st)
+		})
+	}
+}
+
+func TestTx_UpdateWhere_softDelete(t *testing.T) {
+	st, closer := internal.OpenTestStore(t)
+	defer closer()
+	err := st.Register(structWithSoftDelete{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = st.Write(func(tx *bolster.Tx) error {
+		tx.InsertMany([]structWithSoftDelete{{Name: "foo"}, {Name: "bar"}})
+		tx.Delete(&structWithSoftDelete{ID: 1})
+		item := &structWithSoftDelete{}
+		var err error
+		n, err = tx.UpdateWhere(item, bolster.Ge("Name", ""), func() error {
+			item.Name += "!"
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected deleted items not to match, got %d updated items", n)
+	}
+}
+
+type structWithWhereName struct {
+	ID   int
+	Name string `bolster:"index"`
+}
+
+func TestTx_DeleteWhere_sharedPrefix(t *testing.T) {
+	tests := []struct {
+		cond bolster.Cond
+		exp  []int
+	}{
+		{bolster.Eq("Name", "a"), []int{1}},
+		{bolster.Eq("Name", "ab"), []int{2}},
+		{bolster.Lt("Name", "a"), nil},
+		{bolster.Le("Name", "a"), []int{1}},
+		{bolster.Lt("Name", "ab"), []int{1}},
+		{bolster.Le("Name", "ab"), []int{1, 2}},
+		{bolster.Gt("Name", "a"), []int{2, 3}},
+		{bolster.Ge("Name", "ab"), []int{2, 3}},
+		{bolster.Gt("Name", "ab"), []int{3}},
+	}
+	for _, test := range tests {
+		t.Run(test.cond.String(), func(t *testing.T) {
+			st, closer := internal.OpenTestStore(t)
+			defer closer()
+			err := st.Register(structWithWhereName{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deleted []int
+			err = st.Write(func(tx *bolster.Tx) error {
+				tx.InsertMany([]structWithWhereName{{1, "a"}, {2, "ab"}, {3, "b"}})
+				_, err := tx.DeleteWhere(structWithWhereName{}, test.cond)
+				if err != nil {
+					return err
+				}
+				for _, id := range []int{1, 2, 3} {
+					if tx.Get(&structWithWhereName{}, id) != nil {
+						deleted = append(deleted, id)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(deleted, test.exp) {
+				t.Errorf("expected items %v to be deleted, got %v", test.exp, deleted)
+			}
+		})
+	}
+}